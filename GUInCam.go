@@ -5,15 +5,22 @@ import (
 	"image"
 	"image/color"
 	"image/jpeg"
+	"image/png"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cutter"
 	"github.com/goki/gi/gi"
 	"github.com/goki/gi/gimain"
+	"github.com/goki/gi/oswin/key"
 	"github.com/goki/gi/units"
 	"github.com/goki/ki/ki"
+	"github.com/louis-project/sim"
 	"github.com/pkg/errors"
 	"github.com/r3s/gombine"
 	"gocv.io/x/gocv"
@@ -21,12 +28,49 @@ import (
 
 var modelSelector string
 
+// captureLayout names the face pictures by when they were taken,
+// with the date so they stay unique across days
+const captureLayout = "20060102-150405.000"
+
+// guiMu guards what the GUI buttons set and the camera goroutine
+// reads: the selectors and the capture session
+var guiMu sync.Mutex
+
+// sessionGap is how long after the last picture a new one starts a new family
+const sessionGap = 90 * time.Second
+
+// Capture session, pictures taken close together or before
+// "New Family" is pressed make one family
+var (
+	newFamily   bool
+	family      sim.GroupID
+	lastCapture time.Time
+)
+
+// captureFamily returns the family of a picture taken at when
+func captureFamily(when time.Time) sim.GroupID {
+	guiMu.Lock()
+	defer guiMu.Unlock()
+
+	if newFamily || family == 0 || when.Sub(lastCapture) > sessionGap {
+		family = sim.NewGroupID(when)
+		newFamily = false
+	}
+	lastCapture = when
+	return family
+}
+
+// area returns the area of the rectangle
+func area(rect image.Rectangle) int {
+	return rect.Dx() * rect.Dy()
+}
+
 // AICam is the boilerplate for ssd-facedetection and also returns
 // the cropped image.
 func (tf *TheFarm) AICam(deviceID int, proto, model string) {
 	// start The Farm Gui
 	go gimain.Main(func() {
-		TheFarmGui()
+		TheFarmGui(tf.archetypes, LoadAccessories(tf.accDir))
 	})
 
 	// open capture device
@@ -54,6 +98,9 @@ func (tf *TheFarm) AICam(deviceID int, proto, model string) {
 
 	// FarmGui()
 
+	// Faces are hashed at most once per second for eviction
+	lastSeenCheck := time.Now()
+
 	//--------------------------------------//
 	// The Camera For Looooooooooooop
 	for {
@@ -92,6 +139,7 @@ func (tf *TheFarm) AICam(deviceID int, proto, model string) {
 		defer detections.Close()
 
 		var rect image.Rectangle
+		var faces []image.Rectangle
 
 		for r := 0; r < detections.Rows(); r++ {
 			// you would want the classid for general object detection,
@@ -116,74 +164,132 @@ func (tf *TheFarm) AICam(deviceID int, proto, model string) {
 
 			// draw it
 			rect = image.Rect(int(left), int(top), int(right), int(bottom))
+			faces = append(faces, rect)
 			gocv.Rectangle(&img, rect, color, 3)
 		}
 
+		// Tell the farm whom the tracker is seeing
+		if len(faces) > 0 && time.Since(lastSeenCheck) > time.Second {
+			lastSeenCheck = time.Now()
+			for _, face := range faces {
+				region := img.Region(face)
+				faceImg, err := region.ToImage()
+				region.Close()
+				if err != nil {
+					continue
+				}
+				select {
+				case tf.seenFaces <- FaceHash(faceImg):
+				default: // the farm is busy, skip this one
+				}
+			}
+		}
+
 		window.WaitKey(1)
-		// Snap and crop here
-		if modelSelector != "" {
+		guiMu.Lock()
+		model, acc, name := modelSelector, accSelector, nameSelector
+		modelSelector, accSelector, nameSelector = "", "", ""
+		guiMu.Unlock()
+
+		// Snap and crop here, every face in the picture
+		// becomes a character of the same family
+		if model != "" {
 			tmp := "tmp.jpg"
 			CT := time.Now()
 			gocv.IMWrite(tmp, img)
-			croppedImg, err := Cropper(rect, tmp)
-			Errs("Error cropping image", err)
-
-			// Write out the file (image)
-			saveFile := fmt.Sprintf(filepath.Join(tf.faceDir, "%v:%v:%v.jpg"),
-				CT.Hour(), CT.Minute(), CT.Second())
-			filewriter, err := os.Create(saveFile)
-			Errs("Error creating file for jpeg.Encode", err)
-			jpeg.Encode(filewriter, croppedImg, &jpeg.Options{
-				Quality: 80, // Best quality
-			})
 
-			tf.GombineSaveNLoad(saveFile)
+			family := captureFamily(CT)
+			sort.Slice(faces, func(i, j int) bool {
+				return area(faces[i]) > area(faces[j])
+			})
+			archs := tf.groupArchetypes(model, len(faces))
+			if len(faces) == 0 {
+				log.Debug("No face to snap")
+			}
 
-			modelSelector = ""
+			for i, face := range faces {
+				croppedImg, err := Cropper(face, tmp)
+				Errs("Error cropping image", err)
+
+				// Hash the bare face, then dress up the biggest
+				// one with the chosen overlay
+				spec := CharSpec{Archetype: archs[i], Group: family}
+				if i == 0 {
+					spec.Acc = acc
+					spec.Name = name
+				}
+				spec.FaceHash = FaceHash(croppedImg)
+				croppedImg, err = tf.DressFace(croppedImg, spec.Acc)
+				if err != nil {
+					log.Debug("Error dressing face with %v: %v", spec.Acc, err)
+				}
+
+				// Write out the file (image)
+				spec.Face = filepath.Join(tf.faceDir,
+					fmt.Sprintf("%v-%v.jpg", CT.Format(captureLayout), i))
+				filewriter, err := os.Create(spec.Face)
+				Errs("Error creating file for jpeg.Encode", err)
+				jpeg.Encode(filewriter, croppedImg, &jpeg.Options{
+					Quality: 80, // Best quality
+				})
+				filewriter.Close()
+
+				tf.GombineSaveNLoad(spec)
+			}
 		}
 		window.IMShow(img)
 	}
 }
 
-// GombineSaveNLoad will gombine and save the facial image
-// and load the model with the saved image.
-func (tf *TheFarm) GombineSaveNLoad(fileFace string) {
+// GombineSaveNLoad will gombine and save the facial image spec.Face
+// and ask the farm to load the model with the saved image.
+func (tf *TheFarm) GombineSaveNLoad(spec CharSpec) {
+	fileFace := spec.Face
 	// Here MODEL SELECTION and GOMBINE will occur.
-	var fmodel string
-	var fmodelgltf string
-
-	switch modelSelector {
-	case "Son":
-		fmodel = filepath.Join(tf.charDir, "Son.jpg")
-		fmodelgltf = filepath.Join(tf.charDir, "Son.gltf")
-	case "Father":
-		fmodel = filepath.Join(tf.charDir, "Father.jpg")
-		fmodelgltf = filepath.Join(tf.charDir, "Father.gltf")
-	case "Mother":
-		fmodel = filepath.Join(tf.charDir, "Mother.jpg")
-		fmodelgltf = filepath.Join(tf.charDir, "Mother.gltf")
-	case "Daughter":
-		fmodel = filepath.Join(tf.charDir, "Daughter.jpg")
-		fmodelgltf = filepath.Join(tf.charDir, "Daughter.gltf")
+	arch, ok := tf.FindArchetype(spec.Archetype)
+	if !ok {
+		log.Error("Unknown archetype %v selected", spec.Archetype)
+		return
+	}
+
+	// Traits come from the face, the clothes get their tint here
+	if !spec.Traits.Generated() {
+		spec.Traits = arch.RandomTraits(spec.FaceHash)
+	}
+	tintCloth := func(skin image.Image) image.Image {
+		if arch.ClothMask == "" {
+			return skin
+		}
+		mask, err := os.Open(arch.ClothMask)
+		Errs("Error opening cloth mask", err)
+		defer mask.Close()
+		maskImg, err := png.Decode(mask)
+		Errs("Error decoding cloth mask", err)
+		return TintCloth(skin, maskImg, spec.Traits.Tint)
 	}
 
 	images := []*gombine.ImageData{}
-	imdModel := ImageDataGetter(fmodel)
-	imdFace := ImageDataGetter(fileFace)
+	imdModel := ImageDataGetter(arch.Skin, tintCloth)
+	imdFace := ImageDataGetter(fileFace, nil)
 	images = append(images, &imdModel, &imdFace)
 	gombine.ProcessImages(images, "jpg", "bottom", fileFace)
 
-	tf.CreateChar(fmodelgltf, fileFace)
+	// The character is created by the render loop
+	tf.spawnQueue <- spec
 }
 
-// ImageDataGetter returns image data from the provided file
-func ImageDataGetter(file string) gombine.ImageData {
+// ImageDataGetter returns image data from the provided file,
+// changed by edit when it is not nil
+func ImageDataGetter(file string, edit func(image.Image) image.Image) gombine.ImageData {
 	fimg, err := os.Open(file)
 	Errs(fmt.Sprintf("Error opening file %s for gombine", file), err)
 	defer fimg.Close()
 
 	img, err := jpeg.Decode(fimg)
 	Errs(fmt.Sprintf("Error decoding jpeg %v while in ImageDataGetter", file), err)
+	if edit != nil {
+		img = edit(img)
+	}
 
 	imd, err := gombine.GetImageData(&img, file)
 	Errs("Error getting Image Data", err)
@@ -215,7 +321,9 @@ func Cropper(rect image.Rectangle, tmp string) (image.Image, error) {
 	return croppedImg, nil
 }
 
-func TheFarmGui() {
+// TheFarmGui builds the capture GUI, archs are the characters
+// to choose from and accs the accessories to wear.
+func TheFarmGui(archs []Archetype, accs []Accessory) {
 	width := 1024
 	height := 768
 
@@ -260,9 +368,13 @@ func TheFarmGui() {
 	buttonrow1.SetProp("spacing", units.NewValue(3, units.Em))
 	buttonrow1.SetProp("horizontal-align", gi.AlignCenter)
 
-	descriptionRow := gi.AddNewLayout(mfr, "descRow", gi.LayoutHoriz)
-	descriptionRow.SetProp("spacing", units.NewValue(3, units.Em))
-	descriptionRow.SetProp("horizontal-align", gi.AlignCenter)
+	accRow := gi.AddNewLayout(mfr, "accRow", gi.LayoutHoriz)
+	accRow.SetProp("spacing", units.NewValue(2, units.Em))
+	accRow.SetProp("horizontal-align", gi.AlignCenter)
+
+	nameRow := gi.AddNewLayout(mfr, "nameRow", gi.LayoutHoriz)
+	nameRow.SetProp("spacing", units.NewValue(2, units.Em))
+	nameRow.SetProp("horizontal-align", gi.AlignCenter)
 
 	snapButRow := gi.AddNewLayout(mfr, "snapButRow", gi.LayoutHoriz)
 	snapButRow.SetProp("horizontal-align", gi.AlignCenter)
@@ -274,27 +386,9 @@ func TheFarmGui() {
 	title.SetStretchMaxWidth()
 	title.SetStretchMaxHeight()
 
-	// ------------------Descriptions-----------------//
-	descSize := units.NewValue(40, units.Px)
-	desc1 := gi.AddNewLabel(descriptionRow, "desc1", " Father ")
-	desc1.SetProp("text-align", "center")
-	desc1.SetProp("font-size", descSize)
-
-	desc2 := gi.AddNewLabel(descriptionRow, "desc2", "     Son ")
-	desc2.SetProp("text-align", "center")
-	desc2.SetProp("font-size", descSize)
-
-	desc3 := gi.AddNewLabel(descriptionRow, "desc3", "      Mother")
-	desc3.SetProp("text-align", "center")
-	desc3.SetProp("font-size", descSize)
-
-	desc4 := gi.AddNewLabel(descriptionRow, "desc4", "  Daughter")
-	desc4.SetProp("text-align", "center")
-	desc4.SetProp("font-size", descSize)
-
 	// ----------------- Buttons ----------------//
+	descSize := units.NewValue(40, units.Px)
 	iconSize := units.NewValue(10, units.Em)
-	curFocus := 1
 
 	// SnapShot Button
 	butSnap := gi.AddNewButton(snapButRow, "butSnap")
@@ -307,117 +401,124 @@ func TheFarmGui() {
 	descSnap.SetProp("font-size", descSize)
 	descSnap.SetProp("vertical-align", gi.AlignCenter)
 
-	// Family icon buttons
-	button1 := gi.AddNewButton(buttonrow1, "button1")
-	button1.SetIcon("father")
-	button1.SetProp("#icon", ki.Props{
-		"width":  iconSize,
-		"height": iconSize,
-	})
-	button1.SetProp(":focus", ki.Props{
-		"border-color":     "black",
-		"border-width":     units.NewValue(8, units.Px),
-		"background-color": "linear-gradient(samelight-100, highlight-20)",
-	})
-	button1.Tooltip = "click to select your character"
-
-	button2 := gi.AddNewButton(buttonrow1, "button2")
-	button2.SetIcon("son")
-	button2.SetProp("#icon", ki.Props{
-		"width":  iconSize,
-		"height": iconSize,
-	})
-	button2.SetProp(":focus", ki.Props{
-		"border-color":     "black",
-		"border-width":     units.NewValue(8, units.Px),
-		"background-color": "linear-gradient(samelight-100, highlight-20)",
-	})
-	button2.Tooltip = "click to select your character"
-
-	button3 := gi.AddNewButton(buttonrow1, "button3")
-	button3.SetIcon("mom")
-	button3.SetProp("#icon", ki.Props{
-		"width":  iconSize,
-		"height": iconSize,
-	})
-	button3.SetProp(":focus", ki.Props{
-		"border-color":     "black",
-		"border-width":     units.NewValue(8, units.Px),
-		"background-color": "linear-gradient(samelight-100, highlight-20)",
-	})
-	button3.Tooltip = "click to select your character"
+	// New Family Button, the next pictures start a new family
+	butFamily := gi.AddNewButton(snapButRow, "butFamily")
+	butFamily.SetText("New Family")
+	butFamily.SetProp("font-size", descSize)
+	butFamily.Tooltip = "click before the first picture of your family"
+
+	// Family buttons, one per archetype with its name below,
+	// keys 1..9 select them too.
+	modelBuffer := "" // the first archetype is focused initially
+	var firstBut *gi.Button
+	for i, arch := range archs {
+		arch := arch
+		col := gi.AddNewLayout(buttonrow1, "col"+arch.Name, gi.LayoutVert)
+		col.SetProp("horizontal-align", gi.AlignCenter)
+
+		but := gi.AddNewButton(col, "but"+arch.Name)
+		if arch.Icon != "" {
+			but.SetIcon(arch.Icon)
+			but.SetProp("#icon", ki.Props{
+				"width":  iconSize,
+				"height": iconSize,
+			})
+		} else {
+			but.SetText(arch.Name)
+		}
+		but.SetProp(":focus", ki.Props{
+			"border-color":     "black",
+			"border-width":     units.NewValue(8, units.Px),
+			"background-color": "linear-gradient(samelight-100, highlight-20)",
+		})
+		but.Tooltip = arch.Tooltip
+		if i < 9 {
+			but.Shortcut = key.Chord(strconv.Itoa(i + 1))
+		}
 
-	button4 := gi.AddNewButton(buttonrow1, "button4")
-	button4.SetIcon("daughter")
-	button4.SetProp("#icon", ki.Props{
-		"width":  iconSize,
-		"height": iconSize,
-	})
-	button4.SetProp(":focus", ki.Props{
-		"border-color":     "black",
-		"border-width":     units.NewValue(8, units.Px),
-		"background-color": "linear-gradient(samelight-100, highlight-20)",
-	})
-	button4.Tooltip = "click to select your character"
+		desc := gi.AddNewLabel(col, "desc"+arch.Name, arch.Name)
+		desc.SetProp("text-align", "center")
+		desc.SetProp("font-size", descSize)
+
+		but.ButtonSig.Connect(rec.This(),
+			func(recv, send ki.Ki, sig int64, data interface{}) {
+				if sig == int64(gi.ButtonClicked) {
+					send.(*gi.Button).GrabFocus()
+					modelBuffer = arch.Name
+					log.Debug("Archetype %v selected", arch.Name)
+				}
+			})
+		if firstBut == nil {
+			firstBut = but
+			modelBuffer = arch.Name
+		}
+	}
 
-	// -------------------- Button Click ---------------------//
-	modelBuffer := "Father" // initial focused value
-	button1.ButtonSig.Connect(rec.This(),
+	// Accessory buttons, first one takes the accessory off
+	accBuffer := ""
+	accNone := gi.AddNewButton(accRow, "accNone")
+	accNone.SetText("No Accessory")
+	accNone.Tooltip = "click to go without accessory"
+	accNone.ButtonSig.Connect(rec.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			if sig == int64(gi.ButtonReleased) {
-				ButStChanger(curFocus, 1, button1)
-				curFocus = 1
-				modelBuffer = "Father"
+				accBuffer = ""
 			}
 		})
-	button2.ButtonSig.Connect(rec.This(),
-		func(recv, send ki.Ki, sig int64, data interface{}) {
-			if sig == int64(gi.ButtonReleased) {
-				ButStChanger(curFocus, 2, button2)
-				curFocus = 2
-				modelBuffer = "Son"
-			}
-		})
-	button3.ButtonSig.Connect(rec.This(),
-		func(recv, send ki.Ki, sig int64, data interface{}) {
-			if sig == int64(gi.ButtonReleased) {
-				ButStChanger(curFocus, 3, button3)
-				curFocus = 3
-				modelBuffer = "Mother"
-			}
+	for _, acc := range accs {
+		acc := acc
+		butAcc := gi.AddNewButton(accRow, "acc"+acc.Name)
+		butAcc.SetIcon(acc.Icon)
+		butAcc.SetText(acc.Name)
+		butAcc.SetProp(":focus", ki.Props{
+			"border-color": "black",
+			"border-width": units.NewValue(4, units.Px),
 		})
-	button4.ButtonSig.Connect(rec.This(),
+		butAcc.Tooltip = "click to wear the " + acc.Name
+		butAcc.ButtonSig.Connect(rec.This(),
+			func(recv, send ki.Ki, sig int64, data interface{}) {
+				if sig == int64(gi.ButtonReleased) {
+					accBuffer = acc.Name
+				}
+			})
+	}
+
+	// Name of the farmer, a farm name is made up when left empty
+	descName := gi.AddNewLabel(nameRow, "descName", "Your Name")
+	descName.SetProp("font-size", descSize)
+	descName.SetProp("vertical-align", gi.AlignCenter)
+	nameField := gi.AddNewTextField(nameRow, "nameField")
+	nameField.Placeholder = "leave empty for a farm name"
+	nameField.SetProp("font-size", descSize)
+	nameField.SetProp("width", units.NewValue(20, units.Em))
+
+	// -------------------- Button Click ---------------------//
+	butFamily.ButtonSig.Connect(rec.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			if sig == int64(gi.ButtonReleased) {
-				ButStChanger(curFocus, 4, button4)
-				curFocus = 4
-				modelBuffer = "Daughter"
+				guiMu.Lock()
+				newFamily = true
+				guiMu.Unlock()
 			}
 		})
 	butSnap.ButtonSig.Connect(rec.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			if sig == int64(gi.ButtonReleased) {
 				fmt.Println("SnapShot!")
+				guiMu.Lock()
+				accSelector = accBuffer
+				nameSelector = strings.TrimSpace(nameField.Text())
 				modelSelector = modelBuffer
+				guiMu.Unlock()
+				nameField.SetText("")
 			}
 		})
 	win.MainMenuUpdated()
 	vp.UpdateEndNoSig(updt)
-	win.StartEventLoop()
-}
-
-func ButStChanger(curFocus, butClicked int, but *gi.Button) {
-	result := curFocus - butClicked
-	if result < 0 {
-		for i := 0; i > result; i-- {
-			but.FocusNext()
-		}
-	} else {
-		for i := 0; i < result; i++ {
-			but.FocusPrev()
-		}
+	if firstBut != nil {
+		firstBut.GrabFocus()
 	}
-	log.Debug("curFocus: %v ButClicked: %v", curFocus, butClicked)
+	win.StartEventLoop()
 }
 
 // // FarmGui spins up the Gui and returns the selection
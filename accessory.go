@@ -0,0 +1,150 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/g3n/engine/animation"
+	"github.com/g3n/engine/core"
+	xdraw "golang.org/x/image/draw"
+)
+
+// accSelector holds the accessory chosen in the GUI, "" means none
+var accSelector string
+
+// AccessoryKind tells how an accessory is put on the character
+type AccessoryKind int
+
+const (
+	// AccOverlay is a 2D image drawn over the captured face
+	AccOverlay AccessoryKind = iota
+	// AccProp is a small gltf prop attached to the head bone
+	AccProp
+)
+
+// Accessory is something the visitor can dress the farmer with
+type Accessory struct {
+	Name string
+	Icon string
+	Kind AccessoryKind
+	File string // png for overlays, gltf for props, relative to accDir
+
+	// Overlay placement relative to the detected face box,
+	// in fractions of the face width/height.
+	OffX, OffY, Width float32
+
+	// Prop placement relative to the head bone
+	PropPos   [3]float32
+	PropScale float32
+}
+
+// accessories is the full list the GUI can offer, entries
+// whose file is missing from accDir are skipped by LoadAccessories.
+var accessories = []Accessory{
+	{Name: "Glasses", Icon: "glasses", Kind: AccOverlay, File: "glasses.png",
+		OffX: 0.1, OffY: 0.3, Width: 0.8},
+	{Name: "Straw Hat", Icon: "strawhat", Kind: AccProp, File: "strawhat.gltf",
+		PropPos: [3]float32{0, 0.12, 0}, PropScale: 1},
+}
+
+// LoadAccessories returns the accessories available in accDir
+func LoadAccessories(accDir string) []Accessory {
+	var avail []Accessory
+	for _, acc := range accessories {
+		if _, err := os.Stat(filepath.Join(accDir, acc.File)); err != nil {
+			log.Debug("Accessory %v not available: %v", acc.Name, err)
+			continue
+		}
+		avail = append(avail, acc)
+	}
+	return avail
+}
+
+// FindAccessory returns the accessory with the given name
+func FindAccessory(name string) (Accessory, bool) {
+	for _, acc := range accessories {
+		if acc.Name == name {
+			return acc, true
+		}
+	}
+	return Accessory{}, false
+}
+
+// DressFace draws the overlay accessory on top of the cropped face.
+// The face image bounds are the detected face box, so the overlay
+// is placed relative to it. Props are left untouched here.
+func (tf *TheFarm) DressFace(face image.Image, accName string) (image.Image, error) {
+	acc, ok := FindAccessory(accName)
+	if !ok || acc.Kind != AccOverlay {
+		return face, nil
+	}
+
+	file, err := os.Open(filepath.Join(tf.accDir, acc.File))
+	if err != nil {
+		return face, err
+	}
+	defer file.Close()
+	overlay, err := png.Decode(file)
+	if err != nil {
+		return face, err
+	}
+
+	fb := face.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, fb.Dx(), fb.Dy()))
+	draw.Draw(dst, dst.Bounds(), face, fb.Min, draw.Src)
+
+	// keep the overlay aspect ratio, width follows the face width
+	ob := overlay.Bounds()
+	w := int(acc.Width * float32(fb.Dx()))
+	h := w * ob.Dy() / ob.Dx()
+	x := int(acc.OffX * float32(fb.Dx()))
+	y := int(acc.OffY * float32(fb.Dy()))
+	xdraw.ApproxBiLinear.Scale(dst, image.Rect(x, y, x+w, y+h),
+		overlay, ob, draw.Over, nil)
+
+	return dst, nil
+}
+
+// AttachProp loads the prop accessory and adds it to the headBone
+// of the character model. Overlays are baked in the face already.
+// It returns the animations of the prop, if any.
+func (tf *TheFarm) AttachProp(char core.INode, headBone, accName string) []*animation.Animation {
+	acc, ok := FindAccessory(accName)
+	if !ok || acc.Kind != AccProp {
+		return nil
+	}
+	propPath := filepath.Join(tf.accDir, acc.File)
+	if _, err := os.Stat(propPath); err != nil {
+		log.Debug("Prop %v not available: %v", acc.Name, err)
+		return nil
+	}
+
+	bone := findNode(char, headBone)
+	if bone == nil {
+		log.Debug("Head bone %v not found, attaching %v to root", headBone, acc.Name)
+		bone = char
+	}
+
+	prop, anims, _ := tf.loadScene(propPath, "")
+	prop.GetNode().SetName(acc.Name)
+	prop.GetNode().SetPosition(acc.PropPos[0], acc.PropPos[1], acc.PropPos[2])
+	prop.GetNode().SetScale(acc.PropScale, acc.PropScale, acc.PropScale)
+	bone.GetNode().Add(prop)
+	return anims
+}
+
+// findNode returns the first node named name under root
+func findNode(root core.INode, name string) core.INode {
+	if root.GetNode().Name() == name {
+		return root
+	}
+	for _, child := range root.GetNode().Children() {
+		if found := findNode(child, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
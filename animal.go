@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/g3n/engine/animation"
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/math32"
+	"github.com/louis-project/sim"
+)
+
+// AnimalClips are the clips a kind of animal plays
+type AnimalClips struct {
+	Kind   string  // stage gltf files starting with Kind are this animal
+	Rest   string  // clip played resting, the first clip when empty
+	Walk   string  // clip played walking, none when empty
+	Stride float32 // units walked per loop of Walk, measured when 0
+}
+
+var animalClips = []AnimalClips{
+	{Kind: "dog", Rest: "ArmatureAction.005", Walk: "ArmatureAction.002"},
+}
+
+// Animal is the view of a cow or dog of the simulation
+type Animal struct {
+	*sim.Animal
+	Node *core.Node // model root, on the ground under the animal
+
+	Anim               *AnimController
+	restClip, walkClip string // clips of the kind found in the model
+}
+
+// findAnimalClips returns the clips of the kind of animal
+func findAnimalClips(kind string) AnimalClips {
+	for _, clips := range animalClips {
+		if clips.Kind == kind {
+			return clips
+		}
+	}
+	return AnimalClips{Kind: kind}
+}
+
+// NewAnimal makes the view of the animal a of the simulation with the
+// loaded stage model. The model root is moved to the ground under the
+// animal so it walks and turns in place, and the yaw of the body goes
+// to the heading of the animal so the root turns it.
+func NewAnimal(a *sim.Animal, model core.INode, anims []*animation.Animation, targets []*core.Node) *Animal {
+	root := model.GetNode()
+	if kids := root.Children(); len(kids) > 0 {
+		body := kids[0].GetNode()
+		body.SetPosition(0, body.Position().Y, 0)
+		rot := body.Quaternion()
+		var q math32.Quaternion
+		q.SetFromAxisAngle(&math32.Vector3{Y: 1}, -sim.Yaw(rot))
+		body.SetQuaternionQuat(q.Multiply(&rot))
+	}
+
+	av := &Animal{Animal: a, Node: root}
+	clips := findAnimalClips(a.Profile.Kind)
+	av.Anim = NewAnimController(model, anims, targets)
+	if av.Anim.Has(clips.Rest) {
+		av.restClip = clips.Rest
+	} else if clips.Rest == "" && len(anims) > 0 {
+		av.restClip = av.Anim.Names()[0]
+	}
+	if av.Anim.Has(clips.Walk) {
+		av.walkClip = clips.Walk
+	}
+	setStride(av.Anim, a.Name, av.walkClip, sim.HeadingDir(a.Profile.Forward), clips.Stride, 1)
+	av.sync()
+	return av
+}
+
+// sync moves the model where the animal is, its forward on
+// the heading, and plays the walk clip while it moves, else the rest one
+// as authored
+func (av *Animal) sync() {
+	placeNode(av.Node, &av.Body)
+	av.Node.RotateY(-av.Profile.Forward)
+	if av.Moving && av.walkClip != "" {
+		av.Anim.Play(av.walkClip, crossFade)
+		av.Anim.MatchSpeed(av.walkClip, av.Agent.Speed(), av.Profile.Speed)
+	} else if av.restClip != "" {
+		av.Anim.Play(av.restClip, crossFade)
+		av.Anim.ResetRate()
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/g3n/engine/animation"
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/math32"
+)
+
+// crossFade is how long switching clips blends them, seconds
+const crossFade = 0.25
+
+// pose is where a node of the model is
+type pose struct {
+	pos, scale math32.Vector3
+	rot        math32.Quaternion
+}
+
+// AnimController plays the gltf clips of a model one at a time by
+// their name in the gltf, blending from the pose it was in when
+// switching clips.
+type AnimController struct {
+	clips   map[string]*animation.Animation
+	names   []string // clip names in the gltf order
+	model   *core.Node
+	nodes   []*core.Node // nodes of the model the clips move
+	parts   []*core.Node // every node under the model, sampled by measure
+	current string       // clip playing, "" when stopped
+	speed   float32
+
+	from     []pose  // pose blended from, one per node
+	fade     float32 // seconds into the crossfade
+	fadeTime float32 // seconds the crossfade lasts, 0 when not fading
+
+	cycles map[string]cycle // walk clips timed by SetStride or MeasureStride
+}
+
+// NewAnimController returns a stopped *AnimController of the clips of
+// model, blending the target nodes the clips move. Clips without a name,
+// or named like an earlier one, are named clip<i> by their gltf index.
+func NewAnimController(model core.INode, anims []*animation.Animation, targets []*core.Node) *AnimController {
+	ac := &AnimController{
+		clips:  make(map[string]*animation.Animation),
+		model:  model.GetNode(),
+		nodes:  targets,
+		cycles: make(map[string]cycle),
+		speed:  1,
+	}
+	for i, anim := range anims {
+		anim.SetPaused(false)
+		name := anim.Name()
+		if _, dup := ac.clips[name]; dup || name == "" {
+			name = fmt.Sprintf("clip%d", i)
+		}
+		ac.clips[name] = anim
+		ac.names = append(ac.names, name)
+	}
+	var collect func(n core.INode)
+	collect = func(n core.INode) {
+		ac.parts = append(ac.parts, n.GetNode())
+		for _, child := range n.GetNode().Children() {
+			collect(child)
+		}
+	}
+	collect(model)
+	ac.from = make([]pose, len(ac.nodes))
+	return ac
+}
+
+// Names returns the names of the clips in the gltf order
+func (ac *AnimController) Names() []string {
+	return ac.names
+}
+
+// Has returns whether the model has the clip
+func (ac *AnimController) Has(name string) bool {
+	_, ok := ac.clips[name]
+	return ok
+}
+
+// Playing returns the name of the clip playing, "" when stopped
+func (ac *AnimController) Playing() string {
+	return ac.current
+}
+
+// Play switches to the clip from its start, blending into it for fade
+// seconds. Playing the clip already playing does nothing. It returns
+// false when the model has no such clip.
+func (ac *AnimController) Play(name string, fade float32) bool {
+	clip, ok := ac.clips[name]
+	if !ok {
+		return false
+	}
+	if name == ac.current {
+		return true
+	}
+	ac.capture()
+	ac.current = name
+	ac.fade, ac.fadeTime = 0, fade
+	clip.Reset()
+	clip.SetSpeed(ac.speed)
+	return true
+}
+
+// Stop holds the pose the model is in
+func (ac *AnimController) Stop() {
+	ac.current = ""
+	ac.fadeTime = 0
+}
+
+// SetSpeed sets how fast the clips play, 1 is as authored
+func (ac *AnimController) SetSpeed(speed float32) {
+	ac.speed = speed
+	if clip, ok := ac.clips[ac.current]; ok {
+		clip.SetSpeed(speed)
+	}
+}
+
+// Speed returns how fast the clips play
+func (ac *AnimController) Speed() float32 {
+	return ac.speed
+}
+
+// Update advances the clip playing by delta seconds and blends
+// it with the pose it started from while fading
+func (ac *AnimController) Update(delta float32) {
+	clip, ok := ac.clips[ac.current]
+	if !ok {
+		return
+	}
+	clip.Update(delta)
+	if ac.fadeTime <= 0 {
+		return
+	}
+	ac.fade += delta
+	w := Clamp(ac.fade/ac.fadeTime, 0, 1)
+	for i, n := range ac.nodes {
+		from := ac.from[i]
+		pos := from.pos
+		to := n.Position()
+		pos.Lerp(&to, w)
+		n.SetPositionVec(&pos)
+		scale := from.scale
+		to = n.Scale()
+		scale.Lerp(&to, w)
+		n.SetScaleVec(&scale)
+		rot := from.rot
+		q := n.Quaternion()
+		rot.Slerp(&q, w)
+		n.SetQuaternionQuat(&rot)
+	}
+	if w == 1 {
+		ac.fadeTime = 0
+	}
+}
+
+// capture keeps the pose the model is in to blend from
+func (ac *AnimController) capture() {
+	for i, n := range ac.nodes {
+		ac.from[i] = pose{pos: n.Position(), scale: n.Scale(), rot: n.Quaternion()}
+	}
+}
+
+// restore puts the model back in the captured pose
+func (ac *AnimController) restore() {
+	for i, n := range ac.nodes {
+		n.SetPositionVec(&ac.from[i].pos)
+		n.SetScaleVec(&ac.from[i].scale)
+		n.SetQuaternionQuat(&ac.from[i].rot)
+	}
+}
+
+// cycle is how long a walk clip lasts and how far it walks meanwhile
+type cycle struct {
+	duration float32 // seconds
+	stride   float32 // units
+}
+
+const (
+	measureStep = 1.0 / 30 // seconds between the poses sampled by MeasureStride
+	maxClipTime = 60       // seconds, longer clips are not measured
+	minStride   = 0.1      // units, shorter measured strides are clips walking in place
+	minWalkRate = 0.1      // slowest a walk clip plays, when starting or turning
+	maxWalkRate = 2.5      // fastest a walk clip plays
+)
+
+// SetCycle times the clip with c, see MeasureStride
+func (ac *AnimController) SetCycle(name string, c cycle) {
+	if _, ok := ac.clips[name]; ok {
+		ac.cycles[name] = c
+	}
+}
+
+// MeasureStride plays the clip once to time it and guess how far the model
+// walks in one loop: twice the furthest a node swings back and forth along
+// forward, the feet in a walk cycle. forward is the way the model faces in
+// its own frame, the model keeps its pose. Strides under minStride are 0,
+// clips walking in place play at their normal rate.
+func (ac *AnimController) MeasureStride(name string, forward math32.Vector3) (cycle, bool) {
+	c, ok := ac.measure(name, forward)
+	if c.stride < minStride {
+		c.stride = 0
+	}
+	return c, ok
+}
+
+// measure samples the clip from its start until it ends
+func (ac *AnimController) measure(name string, forward math32.Vector3) (cycle, bool) {
+	clip, ok := ac.clips[name]
+	if !ok {
+		return cycle{}, false
+	}
+	ac.capture()
+	loop, speed := clip.Loop(), clip.Speed()
+	clip.SetLoop(false)
+	clip.SetSpeed(1)
+	clip.Reset()
+	clip.SetPaused(false)
+
+	// the world matrices are updated from the top, the model may be scaled
+	root := ac.model
+	for root.Parent() != nil {
+		root = root.Parent().GetNode()
+	}
+	root.UpdateMatrixWorld()
+	var rot math32.Quaternion
+	ac.model.WorldQuaternion(&rot)
+	forward.ApplyQuaternion(&rot).Normalize()
+
+	lo := make([]float32, len(ac.parts))
+	hi := make([]float32, len(ac.parts))
+	var c cycle
+	for c.duration < maxClipTime {
+		clip.Update(measureStep)
+		c.duration += measureStep
+		root.UpdateMatrixWorld()
+		for i, n := range ac.parts {
+			var pos math32.Vector3
+			n.WorldPosition(&pos)
+			along := pos.Dot(&forward)
+			if c.duration == measureStep || along < lo[i] {
+				lo[i] = along
+			}
+			if c.duration == measureStep || along > hi[i] {
+				hi[i] = along
+			}
+		}
+		if clip.Paused() {
+			break
+		}
+	}
+	for i := range ac.parts {
+		c.stride = Max(c.stride, 2*(hi[i]-lo[i]))
+	}
+
+	clip.SetLoop(loop)
+	clip.SetSpeed(speed)
+	clip.Reset()
+	clip.SetPaused(false)
+	ac.restore()
+	return c, true
+}
+
+// MatchSpeed sets the playback rate of the clip, when playing, so the
+// model walks at speed with its stride, the feet do not slide. Clips
+// without stride play at their normal rate at speed nominal.
+func (ac *AnimController) MatchSpeed(name string, speed, nominal float32) {
+	clip, ok := ac.clips[name]
+	if !ok || name != ac.current {
+		return
+	}
+	natural := nominal
+	if c, ok := ac.cycles[name]; ok && c.stride > 0 && c.duration > 0 {
+		natural = c.stride / c.duration
+	}
+	if natural <= 0 {
+		return
+	}
+	clip.SetSpeed(ac.speed * Clamp(speed/natural, minWalkRate, maxWalkRate))
+}
+
+// ResetRate plays the current clip at the speed of the controller again,
+// undoing MatchSpeed
+func (ac *AnimController) ResetRate() {
+	if clip, ok := ac.clips[ac.current]; ok {
+		clip.SetSpeed(ac.speed)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/louis-project/sim"
+	"github.com/pkg/errors"
+)
+
+// defaultHeadBone is the head bone of the Cesium rig most models use
+const defaultHeadBone = "Armature_Bone_20"
+
+// Archetype is a kind of character visitors can choose, one per gltf in
+// charDir: the simulation archetype and how it looks
+type Archetype struct {
+	sim.Archetype
+
+	Icon      string                        `json:"icon"`      // GUI icon, the name is shown when empty
+	Tooltip   string                        `json:"tooltip"`   // GUI tooltip
+	HeadBone  string                        `json:"headBone"`  // bone accessory props are attached to
+	TagHeight float32                       `json:"tagHeight"` // height of the name tag above the feet
+	Stride    float32                       `json:"stride"`    // units walked per loop of the walk clip at height 1, measured when 0
+	Clips     map[sim.BehaviourState]string `json:"clips"`     // gltf clip name of each behaviour state
+	ClothMask string                        `json:"-"`         // path of the picture masking the clothes of the skin
+
+	Model string `json:"-"` // path of the gltf model
+	Skin  string `json:"-"` // path of the skin picture gombined with the face
+}
+
+// LoadArchetypes returns the archetypes of the simulation in charDir with
+// the look the manifest gives them, or the default one.
+func LoadArchetypes(charDir string) []Archetype {
+	simArchs, err := sim.LoadArchetypes(charDir)
+	Errs("Error loading archetypes", err)
+
+	var manifest []Archetype
+	data, err := ioutil.ReadFile(filepath.Join(charDir, sim.ARCHETYPE_MANIFEST))
+	if err == nil {
+		err = json.Unmarshal(data, &manifest)
+		Errs("Error decoding "+sim.ARCHETYPE_MANIFEST, errors.WithStack(err))
+	}
+	looks := make(map[string]Archetype)
+	for _, arch := range manifest {
+		looks[arch.Name] = arch
+	}
+
+	archs := make([]Archetype, len(simArchs))
+	for i, simArch := range simArchs {
+		arch := &archs[i]
+		*arch = looks[simArch.Name]
+		arch.Archetype = simArch
+		arch.Model = filepath.Join(charDir, arch.Name+".gltf")
+		arch.Skin = filepath.Join(charDir, arch.Name+".jpg")
+		if arch.Tooltip == "" {
+			arch.Tooltip = "click to be the " + arch.Name
+		}
+		if arch.HeadBone == "" {
+			arch.HeadBone = defaultHeadBone
+		}
+		if arch.TagHeight == 0 {
+			arch.TagHeight = defaultTagHeight
+		}
+		if mask := filepath.Join(charDir, arch.Name+"_cloth.png"); fileExists(mask) {
+			arch.ClothMask = mask
+		}
+		if _, err := os.Stat(arch.Skin); err != nil {
+			log.Debug("Archetype %v has no jpg skin: %v", arch.Name, err)
+		}
+		log.Debug("Archetype %v found", arch.Name)
+	}
+	return archs
+}
+
+// simArchetypes returns the simulation part of the archetypes
+func simArchetypes(archs []Archetype) []sim.Archetype {
+	simArchs := make([]sim.Archetype, len(archs))
+	for i, arch := range archs {
+		simArchs[i] = arch.Archetype
+	}
+	return simArchs
+}
+
+// groupArchetypes returns the archetypes of n faces snapped together,
+// biggest face first. The first one is the chosen archetype, the others
+// go through the adults and then the children.
+func (tf *TheFarm) groupArchetypes(first string, n int) []string {
+	names := []string{first}
+	var adults, kids []string
+	for _, arch := range tf.archetypes {
+		switch {
+		case arch.Name == first:
+		case arch.Child:
+			kids = append(kids, arch.Name)
+		default:
+			adults = append(adults, arch.Name)
+		}
+	}
+	rest := append(adults, kids...)
+	for i := 1; i < n; i++ {
+		if len(rest) == 0 {
+			names = append(names, first)
+			continue
+		}
+		names = append(names, rest[(i-1)%len(rest)])
+	}
+	return names
+}
+
+// fileExists returns whether the file can be found
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// FindArchetype returns the archetype with the given name
+func (tf *TheFarm) FindArchetype(name string) (Archetype, bool) {
+	for _, arch := range tf.archetypes {
+		if arch.Name == name {
+			return arch, true
+		}
+	}
+	return Archetype{}, false
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/louis-project/sim"
+)
+
+// clipTurn names the turn in place clip in the archetype clips, it is not a state
+const clipTurn = "turn"
+
+// loadClips maps the states to the clips of the character model.
+// Clips are named by the archetype, walk defaults to the first clip.
+func loadClips(arch Archetype, ac *AnimController) map[sim.BehaviourState]string {
+	clips := make(map[sim.BehaviourState]string)
+	for state, name := range arch.Clips {
+		if !ac.Has(name) {
+			log.Debug("%v has no clip %q for %v, it has %v", arch.Name, name, state, ac.Names())
+			continue
+		}
+		clips[state] = name
+	}
+	if names := ac.Names(); clips[sim.StateWalk] == "" && len(names) > 0 {
+		clips[sim.StateWalk] = names[0]
+	}
+	return clips
+}
+
+// playState crossfades to the clip of the current state. A state
+// without a clip plays the idle clip, or the walk clip without one,
+// only a model without clips holds the pose.
+func playState(C *TheChar) {
+	for _, state := range []sim.BehaviourState{C.Behaviour, sim.StateIdle, sim.StateWalk} {
+		if clip, ok := C.Clips[state]; ok {
+			C.Anim.Play(clip, crossFade)
+			return
+		}
+	}
+	C.Anim.Stop()
+}
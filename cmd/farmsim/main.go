@@ -0,0 +1,75 @@
+// Command farmsim runs the farm simulation without window, OpenGL, audio
+// nor camera and writes where every character and animal is at each tick.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/g3n/engine/util/logger"
+	"github.com/louis-project/sim"
+)
+
+var log *logger.Logger
+
+func main() {
+	assets := flag.String("assets", "assets", "assets directory with the stage and the characters")
+	ticks := flag.Int("ticks", 3600, "number of steps simulated")
+	step := flag.Float64("step", 1.0/30, "seconds per step")
+	seed := flag.Int64("seed", 0, "random seed, the same seed replays the same run, 0 picks one")
+	maxChars := flag.Int("maxchars", 11, "number of characters coming in, 2 seconds apart")
+	out := flag.String("out", "trajectories.csv", "file the trajectories are written to, JSON when it ends in .json, else CSV")
+	showLog := flag.Bool("debug", false, "display the debug log")
+	flag.Parse()
+
+	log = logger.New("farmsim", nil)
+	log.AddWriter(logger.NewConsole(false))
+	log.SetFormat(logger.FTIME | logger.FMICROS)
+	if *showLog {
+		log.SetLevel(logger.DEBUG)
+	}
+	sim.SetLogger(log)
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	log.Info("Random seed %v", *seed)
+
+	charDir := filepath.Join(*assets, "character")
+	conf, err := sim.LoadBehaviour(charDir)
+	if err != nil {
+		log.Fatal("Error loading the behaviour: %v", err)
+	}
+	archs, err := sim.LoadArchetypes(charDir)
+	if err != nil {
+		log.Fatal("Error loading the archetypes: %v", err)
+	}
+	stage, err := sim.LoadStage(filepath.Join(*assets, "stage"), conf)
+	if err != nil {
+		log.Fatal("Error loading the stage: %v", err)
+	}
+	world := sim.NewWorld(stage, archs, conf, time.Now(), rand.New(rand.NewSource(*seed)))
+	run := world.Run(*ticks, float32(*step), *maxChars)
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Fatal("Error creating %v: %v", *out, err)
+	}
+	if strings.HasSuffix(*out, ".json") {
+		err = json.NewEncoder(file).Encode(run)
+	} else {
+		err = run.WriteCSV(file)
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Fatal("Error writing %v: %v", *out, err)
+	}
+	log.Info("Wrote %v ticks of %v characters to %v", *ticks, *maxChars, *out)
+}
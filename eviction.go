@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math/rand"
+
+	"github.com/louis-project/sim"
+	"github.com/pkg/errors"
+)
+
+// defaultMaxChars is how many characters the farm keeps without -maxchars
+const defaultMaxChars = 11
+
+// EvictionPolicy picks the character that leaves when the farm is full
+type EvictionPolicy interface {
+	// Victim returns the character to evict among chars,
+	// or false when none of them may leave.
+	Victim(chars []*TheChar) (*TheChar, bool)
+}
+
+// OldestFirst evicts the character created first
+type OldestFirst struct{}
+
+// Victim satisfies the EvictionPolicy interface
+func (OldestFirst) Victim(chars []*TheChar) (*TheChar, bool) {
+	var victim *TheChar
+	for _, char := range chars {
+		if victim == nil || char.Created.Before(victim.Created) {
+			victim = char
+		}
+	}
+	return victim, victim != nil
+}
+
+// LeastRecentlySeen evicts the character whose visitor the
+// face tracker has not seen for the longest time
+type LeastRecentlySeen struct{}
+
+// Victim satisfies the EvictionPolicy interface
+func (LeastRecentlySeen) Victim(chars []*TheChar) (*TheChar, bool) {
+	var victim *TheChar
+	for _, char := range chars {
+		if victim == nil || char.LastSeen.Before(victim.LastSeen) {
+			victim = char
+		}
+	}
+	return victim, victim != nil
+}
+
+// RandomEvict evicts any character
+type RandomEvict struct {
+	rng *rand.Rand
+}
+
+// Victim satisfies the EvictionPolicy interface
+func (re RandomEvict) Victim(chars []*TheChar) (*TheChar, bool) {
+	if len(chars) == 0 {
+		return nil, false
+	}
+	return chars[re.rng.Intn(len(chars))], true
+}
+
+// NeverEvictPinned lets Policy choose among the characters
+// that are not pinned
+type NeverEvictPinned struct {
+	Policy EvictionPolicy
+}
+
+// Victim satisfies the EvictionPolicy interface
+func (nep NeverEvictPinned) Victim(chars []*TheChar) (*TheChar, bool) {
+	var unpinned []*TheChar
+	for _, char := range chars {
+		if !char.Pinned {
+			unpinned = append(unpinned, char)
+		}
+	}
+	return nep.Policy.Victim(unpinned)
+}
+
+// NewEvictionPolicy returns the policy called name: oldest, seen,
+// random or pinned, which is oldest too. Every policy keeps the
+// pinned characters. The random policy draws from rng.
+func NewEvictionPolicy(name string, rng *rand.Rand) (EvictionPolicy, error) {
+	switch name {
+	case "oldest", "pinned":
+		return NeverEvictPinned{OldestFirst{}}, nil
+	case "seen":
+		return NeverEvictPinned{LeastRecentlySeen{}}, nil
+	case "random":
+		return NeverEvictPinned{RandomEvict{rng}}, nil
+	}
+	return nil, errors.Errorf("unknown eviction policy %q", name)
+}
+
+// EnforceCap sends characters out of the farm until the population
+// fits maxChars, the ones already leaving do not count. The character
+// just created, newID, is never the one sent out.
+func (tf *TheFarm) EnforceCap(newID sim.CharID) {
+	for {
+		var staying, candidates []*TheChar
+		tf.chars.Each(func(char *TheChar) bool {
+			if char.State != sim.CharDespawning {
+				staying = append(staying, char)
+				if char.ID != newID {
+					candidates = append(candidates, char)
+				}
+			}
+			return true
+		})
+		if len(staying) <= tf.maxChars {
+			return
+		}
+		victim, ok := tf.evictPolicy.Victim(candidates)
+		if !ok {
+			log.Debug("Farm is over its cap of %v but nobody may leave", tf.maxChars)
+			return
+		}
+		log.Debug("Evicting %v", victim.Name)
+		tf.Despawn(victim.ID)
+	}
+}
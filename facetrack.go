@@ -0,0 +1,57 @@
+package main
+
+import (
+	"image"
+	"math/bits"
+	"time"
+
+	"github.com/louis-project/sim"
+	xdraw "golang.org/x/image/draw"
+)
+
+// maxFaceDist is the largest hash distance still taken as the same face
+const maxFaceDist = 10
+
+// waveGap is how long a visitor is away before the character waves again
+const waveGap = 30 * time.Second
+
+// FaceHash returns the average hash of the face image, it is cheap
+// and good enough to tell the few visitors of a session apart.
+func FaceHash(face image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 8, 8))
+	xdraw.ApproxBiLinear.Scale(small, small.Bounds(), face, face.Bounds(), xdraw.Src, nil)
+
+	var sum int
+	for _, p := range small.Pix {
+		sum += int(p)
+	}
+	avg := uint8(sum / len(small.Pix))
+
+	var hash uint64
+	for i := 0; i < 64; i++ {
+		if small.GrayAt(i%8, i/8).Y >= avg {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// MarkSeen updates the character whose face looks like the one the
+// tracker just saw. It must be called from the render loop.
+func (tf *TheFarm) MarkSeen(hash uint64, when time.Time) {
+	var seen *TheChar
+	best := maxFaceDist + 1
+	tf.chars.Each(func(char *TheChar) bool {
+		if dist := bits.OnesCount64(char.FaceHash ^ hash); dist < best {
+			best, seen = dist, char
+		}
+		return true
+	})
+	if seen != nil {
+		// wave at visitors coming back
+		if when.Sub(seen.LastSeen) > waveGap {
+			tf.world.Event(seen.Char, sim.EventSeen)
+		}
+		seen.LastSeen = when
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/g3n/engine/core"
+	"github.com/louis-project/sim"
+	"github.com/louis-project/tween"
+)
+
+// setupGate finds the spawn gate node in the stage, or puts one where
+// the simulation has the gate. The gate node carries the creation sound.
+func (tf *TheFarm) setupGate() {
+	if gate := findNode(tf.stage.scene, sim.SpawnGateNode); gate != nil {
+		tf.gate = gate.GetNode()
+		return
+	}
+	tf.gate = core.NewNode()
+	tf.gate.SetName(sim.SpawnGateNode)
+	tf.gate.SetPositionVec(&tf.world.Stage.Gate)
+	tf.stageScene.Add(tf.gate)
+}
+
+// startSpawn shrinks the new character to nothing, a tween grows it
+// back to its traits size while the simulation spawns it, and plays
+// the creation sound.
+func (tf *TheFarm) startSpawn(char *TheChar) {
+	scaleModel(char, 1)
+	size := char.Model.GetNode().Scale()
+	scaleModel(char, 0)
+	grow := tween.ScaleTo(char.Model.GetNode(), size, sim.SpawnTime, tween.EaseOutQuad)
+	char.Fx = tf.stage.Add(grow.OnDone(func(interface{}) {
+		char.Fx = nil
+	}, nil))
+	tf.PlaySound(tf.charCreateSnd, tf.gate)
+}
+
+// stopFx cancels the spawn or fade tween of the character, if it still plays
+func stopFx(char *TheChar) {
+	if char.Fx != nil {
+		char.Fx.Cancel()
+		char.Fx = nil
+	}
+}
+
+// Despawn sends the character walking out of the gate, it fades
+// out there and is removed. Use RemoveChar to remove it at once.
+func (tf *TheFarm) Despawn(id sim.CharID) {
+	char, ok := tf.chars.Get(id)
+	if !ok || char.State == sim.CharDespawning {
+		return
+	}
+	if char.State == sim.CharSpawning {
+		stopFx(char)
+		scaleModel(char, 1)
+	}
+	tf.world.Despawn(id)
+}
+
+// scaleModel scales the model to s times its traits size
+func scaleModel(char *TheChar, s float32) {
+	t := char.Traits
+	char.Model.GetNode().SetScale(t.Height*t.Build*s, t.Height*s, t.Height*t.Build*s)
+}
+
+// fadeAlpha returns how opaque a leaving character still is
+func fadeAlpha(char *TheChar) float32 {
+	return 1 - char.Faded()
+}
@@ -1,11 +1,8 @@
 package main
 
 import (
-	"math"
-	"math/rand"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/g3n/engine/core"
 	"github.com/g3n/engine/math32"
@@ -13,6 +10,8 @@ import (
 	"github.com/g3n/engine/animation"
 	"github.com/g3n/engine/loader/gltf"
 	"github.com/g3n/g3nd/util"
+	"github.com/louis-project/sim"
+	"github.com/louis-project/tween"
 	"github.com/pkg/errors"
 )
 
@@ -22,74 +21,200 @@ type GltfLoader struct {
 	anims      []*animation.Animation
 }
 
-// TheChar is the character generated by input facial picture
+// TheChar is the view of a character of the simulation: its model,
+// name tag and clips, moved to where the simulation puts it.
 type TheChar struct {
-	CN *core.Node      // That particular Character Node
-	CD *math32.Vector3 // The character current ongoing destination
-	CO *math32.Vector3 // Current Origin
-	// cT string          // character Type: Son, Father, Mother, Daughter
+	*sim.Char
+
+	CN        *core.Node // That particular Character Node
+	CaptureID string     // Face picture the character was made from
+	Acc       string     // Accessory chosen at capture time, "" for none
+
+	Anims []*animation.Animation // gltf animations of the props, the model ones are in Anim
+	Tag   *NameTag               // Name tag floating above the character
+	Model core.INode             // Loaded gltf model, child of CN
+
+	Clips    map[sim.BehaviourState]string // Model clip of each state
+	Anim     *AnimController               // Plays the model clips
+	TurnClip string                        // Turn in place clip, "" if the model has none
+
+	Fx tween.Tween // Spawn or fade effect playing, nil when done
+}
+
+// cullRadius is how far around its node a model may reach, to tell if it is in view
+const cullRadius = 1.5
+
+// CharSpec describes a character to be created
+type CharSpec struct {
+	Archetype string      // Name of the Archetype
+	Face      string      // Path of the gombined face picture
+	Acc       string      // Accessory name, "" for none
+	Name      string      // Display name, "" for a generated one
+	FaceHash  uint64      // Hash of the captured face
+	Group     sim.GroupID // Family to join, 0 for none
+	Traits    sim.Traits  // Traits of the character, generated when not set
 }
 
 // GenerateNewChar will return a new pointer to TheChar
-// with the face input
-func (tf *TheFarm) GenerateNewChar(modelPath, faceID string) *TheChar {
-	newchar := new(TheChar)
+// showing the character c of the simulation built from the spec
+func (tf *TheFarm) GenerateNewChar(c *sim.Char, spec CharSpec) (*TheChar, error) {
+	arch, ok := tf.FindArchetype(c.Archetype)
+	if !ok {
+		return nil, errors.Errorf("unknown archetype %q", c.Archetype)
+	}
+	newchar := &TheChar{Char: c}
 	newchar.CN = core.NewNode()
-	n := tf.loadScene(modelPath, faceID)
+	n, anims, targets := tf.loadScene(arch.Model, spec.Face)
 	newchar.CN.Add(n)
-	newchar.CO = math32.NewVec3() // assign the origin to be 0,0,0
-	newchar.CD = tf.randCoord()
-
-	return newchar
+	newchar.Model = n
+	propAnims := tf.AttachProp(n, arch.HeadBone, spec.Acc)
+	newchar.Anims = propAnims
+	newchar.Anim = NewAnimController(n, anims, targets)
+	newchar.Clips = loadClips(arch, newchar.Anim)
+	if newchar.TurnClip = newchar.Clips[clipTurn]; newchar.TurnClip == "" {
+		newchar.TurnClip = findTurnClip(newchar.Anim)
+	}
+	// without a look clip the character looks around by turning
+	_, hasLook := newchar.Clips[sim.StateLook]
+	c.LookTurns = !hasLook
+	newchar.Tag = tf.NewNameTag(c.Name, arch.TagHeight)
+	newchar.CN.Add(newchar.Tag.sprite)
+	// the stride is measured at height 1, before the traits scale the model
+	setStride(newchar.Anim, arch.Model, newchar.Clips[sim.StateWalk], math32.Vector3{X: 1}, arch.Stride, c.Traits.Height)
+	tf.applyTraits(newchar, arch)
+	newchar.CaptureID = filepath.Base(spec.Face)
+	newchar.Acc = spec.Acc
+	placeNode(newchar.CN, &c.Body)
+	playState(newchar)
+
+	return newchar, nil
 }
 
-// Render is to update gltf animation.
+// Render is to update gltf animation. Every character, animal and the
+// stage play their own clips, the ones the camera does not see hold
+// their pose until they come back in view.
 func (tf *TheFarm) Render(delta float32) {
-
-	for _, anim := range tf.anims {
-		anim.Update(delta)
+	view := tf.viewFrustum()
+	tf.chars.Each(func(char *TheChar) bool {
+		if inView(char.CN, view) {
+			char.Anim.Update(delta)
+			updateAnims(char.Anims, delta)
+		}
+		return true
+	})
+	for _, a := range tf.animals {
+		if inView(a.Node, view) {
+			a.Anim.Update(delta)
+		}
 	}
+	updateAnims(tf.stage.anims, delta)
+}
+
+// viewFrustum returns what the camera sees
+func (tf *TheFarm) viewFrustum() *math32.Frustum {
+	var view, proj math32.Matrix4
+	tf.camera.ViewMatrix(&view)
+	tf.camera.ProjMatrix(&proj)
+	proj.Multiply(&view)
+	return math32.NewFrustumFromMatrix(&proj)
 }
 
-// MoveChar moves the all the characters
-// to a random destination within a boundary.
-func (tf *TheFarm) MoveChar() {
+// inView returns whether the model of node may be in view
+func inView(node *core.Node, view *math32.Frustum) bool {
+	var pos math32.Vector3
+	node.WorldPosition(&pos)
+	return view.IntersectsSphere(math32.NewSphere(&pos, cullRadius))
+}
 
-	for _, char := range tf.allChar {
-		tf.translateChar(char)
+// updateAnims advances the clips by delta seconds
+func updateAnims(anims []*animation.Animation, delta float32) {
+	for _, anim := range anims {
+		anim.Update(delta)
 	}
 }
 
-func (tf *TheFarm) translateChar(C *TheChar) {
-	tol := float32(0.1)
-	movSpeed := float32(0.0025)
-	distX := C.CD.X - C.CO.X
-	distZ := C.CD.Z - C.CO.Z
-	dX, dZ := Sign(distX)*movSpeed, Sign(distZ)*movSpeed
-	CX, CZ := C.CN.Position().X, C.CN.Position().Z
+// SyncViews moves the character and animal nodes to where the
+// simulation put them, and plays the clips of what they do.
+func (tf *TheFarm) SyncViews() {
+	tf.chars.Each(func(char *TheChar) bool {
+		tf.syncChar(char)
+		return true
+	})
+	for _, a := range tf.animals {
+		a.sync()
+	}
+}
 
-	if !AlmostEq(CX, C.CD.X, tol) {
-		C.CN.SetPositionX(CX + dX) // Move node
+// syncChar moves the character node and plays the turn clip while
+// it turns on the spot, when the model has one, else the clip of its state.
+// Only walking matches the clip rate to the speed, a state falling back
+// on the walk clip plays it as authored.
+// A leaving character fades out once it is at the gate.
+func (tf *TheFarm) syncChar(C *TheChar) {
+	placeNode(C.CN, &C.Body)
+	if C.Turning && C.TurnClip != "" {
+		C.Anim.Play(C.TurnClip, crossFade)
 	} else {
-		C.CO.X = C.CD.X
-		C.CD.X = tf.randPoint(-2, 8)
+		playState(C)
 	}
-	if !AlmostEq(CZ, C.CD.Z, tol) {
-		C.CN.SetPositionZ(CZ + dZ)
+	if C.State != sim.CharSpawning && C.Behaviour == sim.StateWalk {
+		C.Anim.MatchSpeed(C.Clips[sim.StateWalk], C.Agent.Speed(), C.Agent.MaxSpeed)
 	} else {
-		C.CO.Z = C.CD.Z
-		C.CD.Z = tf.randPoint(-8, 6)
+		C.Anim.ResetRate()
+	}
+	if C.AtGate() && C.Fx == nil {
+		C.Fx = tf.stage.Add(tween.FadeTo(C.Model, 1, 0, sim.FadeTime, tween.Linear))
 	}
+}
+
+// placeNode puts the node where the body is, facing its heading
+func placeNode(node *core.Node, b *sim.Body) {
+	pos := b.Pos()
+	node.SetPositionX(pos.X)
+	node.SetPositionZ(pos.Z)
+	var q math32.Quaternion
+	q.SetFromAxisAngle(&math32.Vector3{Y: 1}, b.Heading)
+	node.SetQuaternionQuat(&q)
+}
 
-	rad := math32.Atan2(distX, distZ) - math32.Pi/2
+// strideKey is a walk clip of a model file
+type strideKey struct{ model, clip string }
 
-	CurrentRot := C.CN.Rotation()
-	if CurrentRot.Y != rad {
-		C.CN.SetRotationY(rad)
+// strides are the walk cycles measured, at height 1, so every
+// character of a model does not play its walk clip again
+var strides = make(map[strideKey]cycle)
+
+// setStride times the walk clip of the model with the stride at height 1,
+// or the one measured along forward when it is 0, times height. The
+// cycle is measured once per model file and clip.
+func setStride(ac *AnimController, model, walk string, forward math32.Vector3, stride, height float32) {
+	if walk == "" {
+		return
+	}
+	key := strideKey{model, walk}
+	c, ok := strides[key]
+	if !ok {
+		if c, ok = ac.MeasureStride(walk, forward); !ok {
+			return
+		}
+		strides[key] = c
+		log.Debug("Stride of %v %v measured %v", model, walk, c.stride)
 	}
+	if stride > 0 {
+		c.stride = stride
+	}
+	c.stride *= height
+	ac.SetCycle(walk, c)
+}
 
-	// log.Debug("\nCurrentPos: %v, CurrentDes: %v, Radian: %v, CRotation: %v",
-	// 	C.CN.Position(), C.CD, rad, CurrentRot)
+// findTurnClip returns the first clip with turn in its name
+func findTurnClip(ac *AnimController) string {
+	for _, name := range ac.Names() {
+		if strings.Contains(strings.ToLower(name), "turn") {
+			return name
+		}
+	}
+	return ""
 }
 
 func Min(x, y float32) float32 {
@@ -130,28 +255,10 @@ func Sign(a float32) float32 {
 	return 0
 }
 
-func (tf *TheFarm) randPoint(lower, upper float64) float32 {
-	nanoTime := time.Now().UnixNano()
-	rand.Seed(nanoTime)
-	rg := float32(math.Abs(upper - lower))
-	x := (rand.Float32() * rg) + float32(lower)
-
-	return x
-}
-
-// randCoord returns a random *Vector3 that is within the boundary
-func (tf *TheFarm) randCoord() *math32.Vector3 {
-	nanoTime := time.Now().UnixNano()
-	rand.Seed(nanoTime)
-	x := (rand.Float32() * 10) - 2 // -2 <= x <= 8
-	y := float32(0.0)
-	z := (rand.Float32() * 14) - 8 // -8 <= z <= 6
-
-	log.Debug("Rand Seed: %v", nanoTime)
-	return math32.NewVector3(x, y, z)
-}
-
-func (tf *TheFarm) loadScene(modelPath, faceID string) core.INode {
+// loadScene loads the gltf model and starts its animations,
+// the animations are returned so the caller owns them, with the nodes
+// they move.
+func (tf *TheFarm) loadScene(modelPath, faceID string) (core.INode, []*animation.Animation, []*core.Node) {
 
 	// TODO move camera or scale scene such that it's nicely framed
 	// TODO do this for other loaders as well
@@ -173,8 +280,11 @@ func (tf *TheFarm) loadScene(modelPath, faceID string) core.INode {
 		// REMEMBER ADD user facial picture HERE!!!!
 		switch itemToLoad {
 		case "character":
-			// faceID is the image of model + face picture
-			g.Images[0].Uri = "face/" + filepath.Base(faceID)
+			// faceID is the image of model + face picture,
+			// without one the model keeps its own skin
+			if faceID != "" {
+				g.Images[0].Uri = "face/" + filepath.Base(faceID)
+			}
 		default: // Other than "character"
 			log.Debug("Default case means to load stage")
 		}
@@ -194,13 +304,25 @@ func (tf *TheFarm) loadScene(modelPath, faceID string) core.INode {
 	n, err := g.LoadScene(defaultSceneIdx)
 	Errs("error loading default scene", err)
 
-	// Create animations
+	// Create animations, and find the nodes they move
+	var anims []*animation.Animation
+	var targets []*core.Node
+	found := make(map[int]bool)
 	for i := range g.Animations {
 		anim, _ := g.LoadAnimation(i)
 		anim.SetLoop(true)
-		tf.anims = append(tf.anims, anim)
+		anims = append(anims, anim)
+		for _, ch := range g.Animations[i].Channels {
+			if found[ch.Target.Node] {
+				continue
+			}
+			found[ch.Target.Node] = true
+			// the loader caches nodes, this is the node in the scene
+			if target, err := g.LoadNode(ch.Target.Node); err == nil {
+				targets = append(targets, target.GetNode())
+			}
+		}
 	}
-
-	return n
+	return n, anims, targets
 
 }
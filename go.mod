@@ -8,7 +8,8 @@ require (
 	github.com/mattn/go-colorable v0.1.0 // indirect
 	github.com/mattn/go-isatty v0.0.4 // indirect
 	github.com/pkg/errors v0.8.1
-	golang.org/x/image v0.0.0-20190118043309-183bebdce1b2 // indirect
+	gocv.io/x/gocv v0.41.0
+	golang.org/x/image v0.0.0-20190118043309-183bebdce1b2
 	golang.org/x/sys v0.0.0-20190204203706-41f3e6584952 // indirect
 	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
@@ -3,10 +3,14 @@ package main
 import (
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 
+	"github.com/g3n/engine/animation"
 	"github.com/g3n/engine/camera"
 	"github.com/g3n/engine/core"
 	"github.com/g3n/engine/texture"
+	"github.com/louis-project/sim"
+	"github.com/louis-project/tween"
 	"github.com/pkg/errors"
 )
 
@@ -16,9 +20,9 @@ type Stage struct {
 	scene  *core.Node
 	camera *camera.Perspective
 
-	toAnimate []*Animation
-	animating bool
-	resetAnim bool
+	tween.Player                        // tweens played by Update, see Add
+	anims        []*animation.Animation // clips of the stage pieces other than the animals
+	animating    bool
 }
 
 // NewFarm Creates new stage for it
@@ -61,8 +65,16 @@ func NewStage(tf *TheFarm, cam *camera.Perspective) *Stage {
 
 		if ext == ".gltf" {
 			file := filepath.Join(tf.stageDir, f.Name())
-			node := tf.loadScene(file, "")
+			node, anims, targets := tf.loadScene(file, "")
+			hideMarkers(node)
 			stg.scene.Add(node)
+			// cows and dog walk around on their own
+			name := strings.TrimSuffix(f.Name(), ext)
+			if a, ok := tf.world.Animal(name); ok {
+				tf.animals = append(tf.animals, NewAnimal(a, node, anims, targets))
+			} else {
+				stg.anims = append(stg.anims, anims...)
+			}
 		}
 	}
 	// node := tf.loadScene(tf.stageDir, "")
@@ -72,6 +84,18 @@ func NewStage(tf *TheFarm, cam *camera.Perspective) *Stage {
 	return stg
 }
 
+// hideMarkers hides the nodes under n that only mark places for the
+// simulation: zones, points of interest, collision boxes and the pasture
+func hideMarkers(n core.INode) {
+	if sim.IsMarker(n.GetNode().Name()) {
+		n.GetNode().SetVisible(false)
+		return
+	}
+	for _, child := range n.GetNode().Children() {
+		hideMarkers(child)
+	}
+}
+
 // NewTexture returns new *texture.Texture2D
 func NewTexture(path string) *texture.Texture2D {
 	tex, err := texture.NewTexture2DFromImage(path)
@@ -80,26 +104,3 @@ func NewTexture(path string) *texture.Texture2D {
 	}
 	return tex
 }
-
-// Update updates all ongoing animations for the level
-func (stg *Stage) Update(timeDelta float64) {
-
-	if stg.resetAnim {
-		stg.resetAnim = false
-		stg.toAnimate = make([]*Animation, 0)
-	}
-
-	newToAnimate := stg.toAnimate
-	stg.toAnimate = make([]*Animation, 0)
-
-	for _, anim := range newToAnimate {
-		if !stg.resetAnim {
-			stillAnimating := anim.Update(timeDelta)
-			if stillAnimating {
-				// copy to new slice
-				stg.toAnimate = append(stg.toAnimate, anim)
-			}
-		}
-	}
-
-}
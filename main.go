@@ -3,13 +3,14 @@ package main
 import (
 	"flag"
 	"fmt"
+	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 
-	"github.com/g3n/engine/animation"
 	"github.com/g3n/engine/audio/al"
 	"github.com/g3n/engine/audio/vorbis"
 	"github.com/g3n/engine/light"
@@ -19,14 +20,23 @@ import (
 	"github.com/g3n/engine/camera/control"
 	"github.com/g3n/engine/core"
 	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/graphic"
 	"github.com/g3n/engine/math32"
 	"github.com/g3n/engine/renderer"
+	"github.com/g3n/engine/text"
 	"github.com/g3n/engine/util/logger"
 	"github.com/g3n/engine/window"
+	"github.com/louis-project/sim"
 )
 
 var log *logger.Logger
 
+const (
+	maxFrameDelta    = 0.1 // longest frame simulated in one go, in seconds
+	maxStepsPerFrame = 5   // most fixed steps simulated per frame
+	keyStep          = 1.0 / 60
+)
+
 // TheFarm is the main struct of the application
 type TheFarm struct {
 	wmgr         window.IWindowManager
@@ -36,21 +46,51 @@ type TheFarm struct {
 	scene        *core.Node
 	camera       *camera.Perspective
 	orbitControl *control.OrbitControl
-	anims        []*animation.Animation
 	addChar      bool
 	dataDir      string
 	faceDir      string
 	stageDir     string
 	charDir      string
+	accDir       string
+	archetypes   []Archetype
 
 	userData  *UserData
 	stepDelta *math32.Vector2
 
 	stageScene     *core.Node
 	stage          *Stage
-	allChar        []*TheChar
+	gate           *core.Node // where characters come in and leave, carries the creation sound
+	animals        []*Animal
+	behaviour      *sim.BehaviourConfig
+	world          *sim.World    // the simulation the characters and animals show
+	chars          *CharRegistry // views of the characters of world
 	audioAvailable bool
 
+	// Population cap and who leaves when it is reached
+	maxChars    int
+	evictPolicy EvictionPolicy
+
+	// Requests from the camera goroutine, handled in the render loop
+	spawnQueue chan CharSpec
+	seenFaces  chan uint64
+
+	sinceSave time.Duration // time since the population was saved
+
+	// The simulation only moves in Simulate
+	rng       *rand.Rand // all the randomness of the simulation
+	fixedStep float64    // seconds per step, 0 to follow the frame rate
+	stepAcc   float64    // time not simulated yet with fixedStep
+
+	// Name tags
+	tagFont  *text.Font
+	showTags bool
+
+	// Picking and selection
+	selection  Selection
+	selectSubs []func(sel Selection)
+	highlight  *graphic.Mesh
+	clickStart math32.Vector2
+
 	//Sound and Sfx
 	musicPlayer   *audio.Player
 	charCreateSnd *audio.Player
@@ -59,7 +99,10 @@ type TheFarm struct {
 // ResetFarm clears all the characters.
 func (tf *TheFarm) ResetFarm() {
 	log.Debug("Reset Farm")
-	tf.allChar = nil
+	tf.chars.Each(func(char *TheChar) bool {
+		tf.RemoveChar(char.ID)
+		return true
+	})
 }
 
 // ToggleFullScreen toggles whether is game is fullscreen or windowed
@@ -73,8 +116,48 @@ func (tf *TheFarm) ToggleFullScreen() {
 func (tf *TheFarm) Update(timeDelta float64) {
 
 	if tf.stage != nil {
-		tf.Render(float32(timeDelta))
-		tf.MoveChar()
+		tf.HandleCamRequests()
+		if tf.fixedStep > 0 {
+			// Same steps whatever the frame rate, so runs can be replayed
+			tf.stepAcc += timeDelta
+			for n := 0; tf.stepAcc >= tf.fixedStep && n < maxStepsPerFrame; n++ {
+				tf.Simulate(float32(tf.fixedStep))
+				tf.stepAcc -= tf.fixedStep
+			}
+			if tf.stepAcc > tf.fixedStep {
+				tf.stepAcc = 0 // too slow to keep up, drop the backlog
+			}
+		} else {
+			tf.Simulate(float32(math.Min(timeDelta, maxFrameDelta)))
+		}
+		tf.UpdateNameTags()
+		tf.updateHighlight()
+		tf.autoSavePopulation(timeDelta)
+	}
+}
+
+// Simulate advances the farm by delta seconds
+func (tf *TheFarm) Simulate(delta float32) {
+	tf.camera.WorldPosition(&tf.world.Camera)
+	tf.Render(delta)
+	tf.world.Step(delta)
+	tf.SyncViews()
+	tf.stage.Update(float64(delta))
+}
+
+// HandleCamRequests creates the characters and marks the faces sent by
+// the camera goroutine. Loading and disposing models touches OpenGL
+// so it has to happen in the render loop.
+func (tf *TheFarm) HandleCamRequests() {
+	for {
+		select {
+		case spec := <-tf.spawnQueue:
+			tf.CreateChar(spec)
+		case hash := <-tf.seenFaces:
+			tf.MarkSeen(hash, tf.world.Clock)
+		default:
+			return
+		}
 	}
 }
 
@@ -84,12 +167,28 @@ func (tf *TheFarm) onKey(evname string, ev interface{}) {
 	switch kev.Keycode {
 	case window.KeyR:
 		tf.ToggleFullScreen()
-	case window.KeyX:
-		tf.translateChar(tf.allChar[0])
-	case window.KeyY:
-		tf.translateChar(tf.allChar[0])
-	case window.KeyZ:
-		tf.translateChar(tf.allChar[0])
+	case window.KeyX, window.KeyY, window.KeyZ:
+		if char, ok := tf.SelectedChar(); ok {
+			tf.world.MoveChar(char.Char, keyStep)
+			tf.syncChar(char)
+		}
+	case window.KeyT:
+		tf.ToggleNameTags()
+	case window.KeyP:
+		if char, ok := tf.SelectedChar(); ok {
+			char.Pinned = !char.Pinned
+			log.Debug("%v pinned: %v", char.Name, char.Pinned)
+		}
+	case window.KeyDelete:
+		if char, ok := tf.SelectedChar(); ok {
+			tf.Despawn(char.ID)
+		}
+	case window.KeyG:
+		if char, ok := tf.SelectedChar(); ok {
+			tf.RemoveGroup(char.Group)
+		}
+	case window.KeyEscape:
+		tf.ClearSelection()
 	case window.KeyEnter:
 		tf.ResetFarm()
 	}
@@ -119,25 +218,68 @@ func (tf *TheFarm) onCursor(evname string, ev interface{}) {
 // CreateChar creates character and add it to the Scene
 // in g3n, Scene is actually *core.Node and adding
 // *core.Node is actually adding object to Scene
-func (tf *TheFarm) CreateChar(modelPath, faceID string) {
+func (tf *TheFarm) CreateChar(spec CharSpec) *TheChar {
 	log.Debug("Creating Character")
 
-	newchar := tf.GenerateNewChar(modelPath, faceID)
-	newchar.CN.SetName(faceID)
-	tf.allChar = append(tf.allChar, newchar)
+	c, err := tf.world.AddChar(sim.CharSpec{
+		Archetype: spec.Archetype,
+		Name:      spec.Name,
+		FaceHash:  spec.FaceHash,
+		Group:     spec.Group,
+		Traits:    spec.Traits,
+	})
+	if err != nil {
+		log.Error("Error creating character: %v", err)
+		return nil
+	}
+	newchar, err := tf.GenerateNewChar(c, spec)
+	if err != nil {
+		log.Error("Error creating character: %v", err)
+		tf.world.Remove(c.ID)
+		return nil
+	}
+	tf.chars.Add(newchar)
+	newchar.CN.SetName(newchar.CaptureID)
+	newchar.CN.SetUserData(newchar.ID)
 	tf.stageScene.Add(newchar.CN)
+	tf.startSpawn(newchar)
 
-	childrenSlice := tf.stageScene.Children()
-
-	maxCharLimit := 11
-	if len(childrenSlice) > maxCharLimit {
-		tf.stageScene.RemoveAt(1)
-	}
+	tf.EnforceCap(newchar.ID)
 
 	// All whole stage is 1 node
 	// Every char has own node
-	log.Debug("New character CREATED!")
+	log.Debug("New character %v CREATED!", newchar.Name)
+
+	return newchar
+}
 
+// RemoveChar takes the character out of the farm at once
+func (tf *TheFarm) RemoveChar(id sim.CharID) {
+	tf.world.Remove(id)
+}
+
+// RemoveGroup takes the whole family out of the farm
+func (tf *TheFarm) RemoveGroup(gid sim.GroupID) {
+	for _, id := range tf.world.GroupMembers(gid) {
+		tf.Despawn(id)
+	}
+}
+
+// dropChar releases the node, animations and textures of the
+// character the simulation removed
+func (tf *TheFarm) dropChar(c *sim.Char) {
+	char, ok := tf.chars.Remove(c.ID)
+	if !ok {
+		return
+	}
+	if tf.selection.Kind == SelChar && tf.selection.Char == c.ID {
+		tf.ClearSelection()
+	}
+	stopFx(char)
+	tf.stageScene.Remove(char.CN)
+	char.Anims = nil
+	char.CN.DisposeChildren(true)
+	log.Debug("Character %v REMOVED!", char.Name)
 }
 
 // LoadStage loads the stage and add to stageScene
@@ -146,11 +288,19 @@ func (tf *TheFarm) LoadStage() {
 
 	// TODO load stage model from Blender
 
+	stage, err := sim.LoadStage(tf.stageDir, tf.behaviour)
+	Errs("Error loading stage", err)
+	tf.world = sim.NewWorld(stage, simArchetypes(tf.archetypes), tf.behaviour, time.Now(), tf.rng)
+	tf.world.OnRemove = tf.dropChar
+
 	tf.stage = NewStage(tf, tf.camera)
 	tf.stage.scene.SetName("Stage Node")
 	tf.stageScene.Add(tf.stage.scene)
+	tf.setupGate()
 	// allow camera movement
-	tf.orbitControl.Enabled = true
+	if tf.orbitControl != nil {
+		tf.orbitControl.Enabled = true
+	}
 }
 
 // LeThereBeLight add light souce along all axes
@@ -212,12 +362,20 @@ func (tf *TheFarm) LoadAudio() {
 
 	tf.musicPlayer = createPlayer(tf.dataDir + "/BGM.ogg")
 	tf.musicPlayer.SetLooping(true)
+
+	// The creation sound is optional
+	fname := tf.dataDir + "/charCreate.wav"
+	if _, err := os.Stat(fname); err == nil {
+		tf.charCreateSnd = createPlayer(fname)
+	} else {
+		log.Debug("No character creation sound: %v", err)
+	}
 }
 
 // PlaySound just play the sound by:
 // PlaySound(tf.musicPlayer, nil)
 func (tf *TheFarm) PlaySound(player *audio.Player, node *core.Node) {
-	if tf.audioAvailable {
+	if tf.audioAvailable && player != nil {
 		if node != nil {
 			node.Add(player)
 		}
@@ -233,6 +391,11 @@ func main() {
 
 	// Parse command line flags
 	showLog := flag.Bool("debug", false, "display the debug log")
+	maxChars := flag.Int("maxchars", defaultMaxChars, "maximum number of characters on the farm")
+	evict := flag.String("evict", "oldest", "eviction policy: oldest, seen, random or pinned")
+	fresh := flag.Bool("fresh", false, "start with an empty farm instead of the saved population")
+	seed := flag.Int64("seed", 0, "random seed, the same seed replays the same wander paths, 0 picks one")
+	fixedStep := flag.Float64("fixedstep", 0, "simulate in fixed steps of that many seconds, 0 follows the frame rate")
 	flag.Parse()
 
 	// Create logger
@@ -242,9 +405,24 @@ func main() {
 	if *showLog == true {
 		log.SetLevel(logger.DEBUG)
 	}
+	sim.SetLogger(log)
 
 	// Create TheFarm struct
 	tf := new(TheFarm)
+	tf.chars = NewCharRegistry()
+	tf.showTags = true
+	tf.maxChars = *maxChars
+	tf.fixedStep = *fixedStep
+	var err error
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	log.Info("Random seed %v", *seed)
+	tf.rng = rand.New(rand.NewSource(*seed))
+	tf.evictPolicy, err = NewEvictionPolicy(*evict, tf.rng)
+	Errs("Error choosing eviction policy", err)
+	tf.spawnQueue = make(chan CharSpec, 8)
+	tf.seenFaces = make(chan uint64, 8)
 
 	// Manually scan the $GOPATH directories to find the data directory
 	rawPaths := os.Getenv("GOPATH")
@@ -257,6 +435,7 @@ func main() {
 			tf.faceDir = filepath.Join(path, "character/face")
 			tf.stageDir = filepath.Join(path, "stage")
 			tf.charDir = filepath.Join(path, "character")
+			tf.accDir = filepath.Join(path, "accessory")
 		}
 	}
 
@@ -268,8 +447,12 @@ func main() {
 	// }
 	tf.userData = NewUserData(tf.dataDir)
 
+	// Every gltf in charDir is a character visitors can choose
+	tf.archetypes = LoadArchetypes(tf.charDir)
+	tf.behaviour, err = sim.LoadBehaviour(tf.charDir)
+	Errs("Error loading behaviour", err)
+
 	// Get the window manager
-	var err error
 	tf.wmgr, err = window.Manager("glfw")
 	Errs("Error getting glfw window manager", err)
 
@@ -352,13 +535,18 @@ func main() {
 		tf.musicPlayer.Play() // uncomment to play the music
 	}
 	tf.LoadStage()
+	if !*fresh {
+		tf.RestorePopulation()
+	}
 	go tf.AICam(0,
 		"assets/data/deploy.prototxt",
 		"assets/data/res10300x300ssd140000.caffemodel")
 
-	// tf.CreateChar(tf.charDir+"/Father.gltf", "1.png")
+	// tf.CreateChar(CharSpec{Archetype: "Father", Face: "1.png"})
 
 	tf.win.Subscribe(window.OnCursor, tf.onCursor)
+	tf.win.Subscribe(window.OnMouseDown, tf.onMouseDown)
+	tf.win.Subscribe(window.OnMouseUp, tf.onMouseUp)
 
 	now := time.Now()
 	newNow := time.Now()
@@ -375,6 +563,9 @@ func main() {
 	}
 
 	tf.userData.Save(tf.dataDir)
+	if err := tf.SavePopulation(); err != nil {
+		log.Error("Error saving population: %v", err)
+	}
 }
 
 // RenderFrame renders a frame of the scene with the GUI overlaid
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/gui/assets"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/text"
+	"github.com/g3n/engine/texture"
+)
+
+// nameSelector holds the name typed in the GUI, "" means generate one
+var nameSelector string
+
+const (
+	defaultTagHeight = 1.8  // tag height above the feet when the archetype has none
+	tagTextHeight    = 0.25 // tag height in world units
+	tagFadeNear      = 6.0  // tags start fading from this camera distance
+	tagFadeFar       = 14.0 // and are gone from this one
+)
+
+// NameTag is the label floating above a character, it always faces the camera
+type NameTag struct {
+	sprite *graphic.Sprite
+	mat    *material.Standard
+}
+
+// NewNameTag returns a name tag showing name at the given height
+func (tf *TheFarm) NewNameTag(name string, height float32) *NameTag {
+	if tf.tagFont == nil {
+		font, err := text.NewFontFromData(assets.MustAsset("fonts/FreeSans.ttf"))
+		Errs("Error loading name tag font", err)
+		font.SetPointSize(48)
+		font.SetColor(&math32.Color4{1, 1, 1, 1})
+		font.SetBgColor(&math32.Color4{0, 0, 0, 0.4})
+		tf.tagFont = font
+	}
+
+	img := tf.tagFont.DrawText(" " + name + " ")
+	tex := texture.NewTexture2DFromRGBA(img)
+	mat := material.NewStandard(math32.NewColor("white"))
+	mat.SetShader("sprite")
+	mat.AddTexture(tex)
+	mat.SetTransparent(true)
+
+	aspect := float32(img.Bounds().Dx()) / float32(img.Bounds().Dy())
+	tag := new(NameTag)
+	tag.mat = mat
+	tag.sprite = graphic.NewSprite(tagTextHeight*aspect, tagTextHeight, mat)
+	tag.sprite.SetPosition(0, height, 0)
+	tag.sprite.SetVisible(tf.showTags)
+	return tag
+}
+
+// UpdateNameTags fades the tags with the distance to the camera
+func (tf *TheFarm) UpdateNameTags() {
+	var camPos math32.Vector3
+	tf.camera.WorldPosition(&camPos)
+
+	tf.chars.Each(func(char *TheChar) bool {
+		if char.Tag == nil {
+			return true
+		}
+		char.Tag.sprite.SetVisible(tf.showTags)
+		var pos math32.Vector3
+		char.Tag.sprite.WorldPosition(&pos)
+		dist := pos.DistanceTo(&camPos)
+		fade := (dist - tagFadeNear) / (tagFadeFar - tagFadeNear)
+		char.Tag.mat.SetOpacity((1 - Clamp(fade, 0, 1)) * fadeAlpha(char))
+		return true
+	})
+}
+
+// ToggleNameTags shows or hides all name tags
+func (tf *TheFarm) ToggleNameTags() {
+	tf.showTags = !tf.showTags
+	log.Debug("Name tags shown: %v", tf.showTags)
+}
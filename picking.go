@@ -0,0 +1,169 @@
+package main
+
+import (
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/window"
+	"github.com/louis-project/sim"
+)
+
+// clickSlop is how many pixels the mouse may move and still be a click,
+// more than that is the orbit control dragging the camera
+const clickSlop = 5
+
+// groundNodes are stage nodes that clear the selection when clicked
+var groundNodes = map[string]bool{
+	"Ground":    true,
+	"Landscape": true,
+}
+
+// SelKind tells what is selected
+type SelKind int
+
+const (
+	// SelNone nothing is selected
+	SelNone SelKind = iota
+	// SelChar a character is selected
+	SelChar
+	// SelProp a stage prop is selected
+	SelProp
+)
+
+// Selection is the entity picked in the 3D view
+type Selection struct {
+	Kind  SelKind
+	Char  sim.CharID     // selected character when Kind is SelChar
+	Node  core.INode     // character node or stage prop node
+	Point math32.Vector3 // where the ray hit, in world coordinates
+}
+
+// Pick casts a ray from the camera through the window position x, y
+// and returns what it hits first, characters and stage props only.
+func (tf *TheFarm) Pick(x, y float32) Selection {
+	width, height := tf.win.Size()
+	sx := 2*(x/float32(width)) - 1
+	sy := -2*(y/float32(height)) + 1
+
+	rc := core.NewRaycaster(&math32.Vector3{}, &math32.Vector3{})
+	tf.camera.SetRaycaster(rc, sx, sy)
+	hits := rc.IntersectObject(tf.stageScene, true)
+
+	for _, hit := range hits {
+		if char, ok := tf.chars.FindByNode(hit.Object); ok {
+			return Selection{Kind: SelChar, Char: char.ID, Node: char.CN, Point: hit.Point}
+		}
+		if prop := tf.stageProp(hit.Object); prop != nil {
+			if groundNodes[prop.GetNode().Name()] {
+				return Selection{}
+			}
+			return Selection{Kind: SelProp, Node: prop, Point: hit.Point}
+		}
+	}
+	return Selection{}
+}
+
+// stageProp returns the prop of the stage the node belongs to, that is
+// the top node of its gltf file, or nil when it is not part of the stage
+func (tf *TheFarm) stageProp(inode core.INode) core.INode {
+	var child, fileRoot core.INode
+	for n := inode; n != nil; n = n.GetNode().Parent() {
+		if n == tf.stage.scene {
+			if child == nil {
+				return fileRoot
+			}
+			return child
+		}
+		child, fileRoot = fileRoot, n
+	}
+	return nil
+}
+
+// Select makes sel the selected entity and tells the subscribers
+func (tf *TheFarm) Select(sel Selection) {
+	tf.selection = sel
+	tf.updateHighlight()
+	switch sel.Kind {
+	case SelChar:
+		if char, ok := tf.chars.Get(sel.Char); ok {
+			log.Info("Selected %v the %v, on the farm since %v",
+				char.Name, char.Archetype, char.Created.Format("15:04:05"))
+		}
+	case SelProp:
+		log.Info("Selected %v", sel.Node.GetNode().Name())
+	}
+	for _, fn := range tf.selectSubs {
+		fn(sel)
+	}
+}
+
+// ClearSelection selects nothing
+func (tf *TheFarm) ClearSelection() {
+	tf.Select(Selection{})
+}
+
+// Selected returns the current selection
+func (tf *TheFarm) Selected() Selection {
+	return tf.selection
+}
+
+// SelectedChar returns the selected character, if a character is selected
+func (tf *TheFarm) SelectedChar() (*TheChar, bool) {
+	if tf.selection.Kind != SelChar {
+		return nil, false
+	}
+	return tf.chars.Get(tf.selection.Char)
+}
+
+// OnSelect calls fn every time the selection changes
+func (tf *TheFarm) OnSelect(fn func(sel Selection)) {
+	tf.selectSubs = append(tf.selectSubs, fn)
+}
+
+// updateHighlight shows the ring around the selected entity
+func (tf *TheFarm) updateHighlight() {
+	if tf.highlight == nil {
+		mat := material.NewStandard(math32.NewColor("gold"))
+		mat.SetEmissiveColor(math32.NewColor("gold"))
+		tf.highlight = graphic.NewMesh(geometry.NewTorus(0.6, 0.04, 8, 32, 2*math32.Pi), mat)
+		tf.highlight.SetRotationX(math32.Pi / 2)
+		tf.highlight.SetVisible(false)
+		tf.scene.Add(tf.highlight)
+	}
+
+	if tf.selection.Kind == SelNone {
+		tf.highlight.SetVisible(false)
+		return
+	}
+	var pos math32.Vector3
+	tf.selection.Node.GetNode().WorldPosition(&pos)
+	if tf.selection.Kind == SelProp {
+		// props origins are not always on the ground, use the hit
+		pos = tf.selection.Point
+	}
+	tf.highlight.SetPosition(pos.X, pos.Y+0.02, pos.Z)
+	tf.highlight.SetVisible(true)
+}
+
+// onMouseDown remembers where a click may start
+func (tf *TheFarm) onMouseDown(evname string, ev interface{}) {
+	mev := ev.(*window.MouseEvent)
+	if mev.Button == window.MouseButtonLeft {
+		tf.clickStart = math32.Vector2{X: mev.Xpos, Y: mev.Ypos}
+	}
+}
+
+// onMouseUp picks what is under the mouse unless the camera was dragged
+func (tf *TheFarm) onMouseUp(evname string, ev interface{}) {
+	mev := ev.(*window.MouseEvent)
+	if mev.Button != window.MouseButtonLeft || tf.stage == nil {
+		return
+	}
+	end := math32.Vector2{X: mev.Xpos, Y: mev.Ypos}
+	if end.DistanceTo(&tf.clickStart) > clickSlop {
+		return
+	}
+	tf.Select(tf.Pick(mev.Xpos, mev.Ypos))
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/g3n/engine/math32"
+	"github.com/louis-project/sim"
+)
+
+// POPULATION_FILENAME filepath of the file used to store the farm population via Gob
+const POPULATION_FILENAME string = "/population.data"
+
+// populationSaveEvery is how often the population is saved while running
+const populationSaveEvery = 60 * time.Second
+
+// CharRecord stores what is needed to bring a character back
+type CharRecord struct {
+	Archetype string
+	CaptureID string // face picture in faceDir
+	Name      string
+	Acc       string
+	FaceHash  uint64
+	Group     sim.GroupID
+	Traits    sim.Traits
+	Pinned    bool
+	Created   time.Time
+	Pos       math32.Vector3
+	Dest      math32.Vector3
+	Origin    math32.Vector3
+}
+
+// Population stores the characters living on the farm, oldest first
+type Population struct {
+	Chars []CharRecord
+}
+
+// SavePopulation saves the current characters to the population file,
+// overwriting the previous one
+func (tf *TheFarm) SavePopulation() error {
+	pop := new(Population)
+	tf.chars.Each(func(char *TheChar) bool {
+		if char.State == sim.CharDespawning {
+			return true // on its way out
+		}
+		pop.Chars = append(pop.Chars, CharRecord{
+			Archetype: char.Archetype,
+			CaptureID: char.CaptureID,
+			Name:      char.Name,
+			Acc:       char.Acc,
+			FaceHash:  char.FaceHash,
+			Group:     char.Group,
+			Traits:    char.Traits,
+			Pinned:    char.Pinned,
+			Created:   char.Created,
+			Pos:       char.Pos(),
+			Dest:      char.Dest,
+			Origin:    char.Origin,
+		})
+		return true
+	})
+	log.Debug("Saving %v characters", len(pop.Chars))
+
+	// Write aside and rename so a power cut can't leave half a file
+	fileName := tf.dataDir + POPULATION_FILENAME
+	newFile, err := os.Create(fileName + ".tmp")
+	if err != nil {
+		log.Debug("Error creating population file: %v", err)
+		return err
+	}
+	err = gob.NewEncoder(newFile).Encode(pop)
+	newFile.Close()
+	if err != nil {
+		log.Debug("Error encoding population: %v", err)
+		return err
+	}
+	return os.Rename(fileName+".tmp", fileName)
+}
+
+// RestorePopulation creates the characters saved in the population file
+func (tf *TheFarm) RestorePopulation() {
+	file, err := os.Open(tf.dataDir + POPULATION_FILENAME)
+	if err != nil {
+		log.Debug("Error opening population file: %v", err)
+		return
+	}
+	defer file.Close()
+
+	pop := new(Population)
+	if err := gob.NewDecoder(file).Decode(pop); err != nil {
+		log.Debug("Error decoding population: %v", err)
+		return
+	}
+
+	for _, rec := range pop.Chars {
+		face := filepath.Join(tf.faceDir, rec.CaptureID)
+		if _, err := os.Stat(face); err != nil {
+			log.Debug("Skipping %v, face is gone: %v", rec.Name, err)
+			continue
+		}
+		char := tf.CreateChar(CharSpec{
+			Archetype: rec.Archetype,
+			Face:      face,
+			Acc:       rec.Acc,
+			Name:      rec.Name,
+			FaceHash:  rec.FaceHash,
+			Group:     rec.Group,
+			Traits:    rec.Traits,
+		})
+		if char == nil {
+			continue
+		}
+		char.Pinned = rec.Pinned
+		char.Created = rec.Created
+		char.LastSeen = rec.Created
+		char.SetPos(rec.Pos)
+		tf.world.Space.Update(&char.Body)
+		char.Dest = rec.Dest
+		char.Origin = rec.Origin
+		placeNode(char.CN, &char.Body)
+	}
+	log.Debug("Restored %v characters", tf.chars.Len())
+}
+
+// autoSavePopulation saves the population every populationSaveEvery
+func (tf *TheFarm) autoSavePopulation(timeDelta float64) {
+	tf.sinceSave += time.Duration(timeDelta * float64(time.Second))
+	if tf.sinceSave >= populationSaveEvery {
+		tf.sinceSave = 0
+		if err := tf.SavePopulation(); err != nil {
+			log.Error("Error saving population: %v", err)
+		}
+	}
+}
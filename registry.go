@@ -0,0 +1,110 @@
+package main
+
+import (
+	"github.com/g3n/engine/core"
+	"github.com/louis-project/sim"
+)
+
+// CharRegistry keeps the view of every character of the farm by id.
+// It is only used from the render loop, the camera goroutine hands
+// its captures and faces over through spawnQueue and seenFaces.
+type CharRegistry struct {
+	chars map[sim.CharID]*TheChar
+	order []sim.CharID // ids in creation order
+}
+
+// NewCharRegistry returns an empty *CharRegistry
+func NewCharRegistry() *CharRegistry {
+	reg := new(CharRegistry)
+	reg.chars = make(map[sim.CharID]*TheChar)
+	return reg
+}
+
+// Add registers the character by the id the simulation gave it
+func (reg *CharRegistry) Add(char *TheChar) {
+	reg.chars[char.ID] = char
+	reg.order = append(reg.order, char.ID)
+}
+
+// Get returns the character with the given id
+func (reg *CharRegistry) Get(id sim.CharID) (*TheChar, bool) {
+	char, ok := reg.chars[id]
+	return char, ok
+}
+
+// Remove unregisters and returns the character with the given id
+func (reg *CharRegistry) Remove(id sim.CharID) (*TheChar, bool) {
+	char, ok := reg.chars[id]
+	if !ok {
+		return nil, false
+	}
+	delete(reg.chars, id)
+	for i, oid := range reg.order {
+		if oid == id {
+			reg.order = append(reg.order[:i], reg.order[i+1:]...)
+			break
+		}
+	}
+	return char, true
+}
+
+// Len returns the number of registered characters
+func (reg *CharRegistry) Len() int {
+	return len(reg.order)
+}
+
+// All returns the characters in creation order
+func (reg *CharRegistry) All() []*TheChar {
+	all := make([]*TheChar, 0, len(reg.order))
+	for _, id := range reg.order {
+		all = append(all, reg.chars[id])
+	}
+	return all
+}
+
+// Each calls fn for every character in creation order until fn
+// returns false. It works on a snapshot so fn may add or remove.
+func (reg *CharRegistry) Each(fn func(char *TheChar) bool) {
+	for _, char := range reg.All() {
+		if !fn(char) {
+			return
+		}
+	}
+}
+
+// First returns the oldest character
+func (reg *CharRegistry) First() (*TheChar, bool) {
+	if len(reg.order) == 0 {
+		return nil, false
+	}
+	return reg.chars[reg.order[0]], true
+}
+
+// Last returns the newest character
+func (reg *CharRegistry) Last() (*TheChar, bool) {
+	if len(reg.order) == 0 {
+		return nil, false
+	}
+	return reg.chars[reg.order[len(reg.order)-1]], true
+}
+
+// FindByCapture returns the character created from the capture id
+func (reg *CharRegistry) FindByCapture(captureID string) (*TheChar, bool) {
+	for _, id := range reg.order {
+		if reg.chars[id].CaptureID == captureID {
+			return reg.chars[id], true
+		}
+	}
+	return nil, false
+}
+
+// FindByNode returns the character owning the node, the node
+// can be anywhere below the character node.
+func (reg *CharRegistry) FindByNode(inode core.INode) (*TheChar, bool) {
+	for n := inode; n != nil; n = n.GetNode().Parent() {
+		if id, ok := n.GetNode().UserData().(sim.CharID); ok {
+			return reg.Get(id)
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,214 @@
+package sim
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/g3n/engine/math32"
+)
+
+const (
+	pastureMargin = 0.5 // pasture around the cows without a Pasture node
+	followRange   = 6.0 // how far the dog notices characters
+	followDist    = 1.0 // how close the dog follows
+	animalTol     = 0.2 // how close to its destination counts as arrived
+)
+
+// AnimalState is what an animal is doing
+type AnimalState int
+
+const (
+	// AnimalRest is grazing, sniffing around, ...
+	AnimalRest AnimalState = iota
+	// AnimalWalk is ambling to a destination
+	AnimalWalk
+	// AnimalFollow is following a character
+	AnimalFollow
+)
+
+// String returns the name of the state
+func (s AnimalState) String() string {
+	return [...]string{"rest", "walk", "follow"}[s]
+}
+
+// AnimalProfile is how a kind of animal behaves
+type AnimalProfile struct {
+	Kind      string  // stage gltf files starting with Kind are this animal
+	Speed     float32 // units per second
+	Rest      Range   // seconds resting between walks
+	UseNav    bool    // roams the farm around the obstacles, else stays in the pasture
+	Follow    float32 // chance to follow the nearest character after resting
+	FollowFor Range   // seconds following
+	Forward   float32 // heading the model faces with the yaw of its body taken out, radians
+}
+
+var animalProfiles = []AnimalProfile{
+	{Kind: "cow", Speed: 0.08, Rest: Range{6, 15}, Forward: -125 * math32.Pi / 180},
+	{Kind: "dog", Speed: 0.5, Rest: Range{2, 5}, UseNav: true,
+		Follow: 0.4, FollowFor: Range{5, 12}, Forward: -105 * math32.Pi / 180},
+}
+
+// Animal is a cow or dog of the stage walking on its own
+type Animal struct {
+	Body
+	Name    string
+	Profile AnimalProfile
+	State   AnimalState
+	Until   time.Time      // end of the rest or the follow
+	Dest    math32.Vector3 // where it walks to
+	Follow  CharID         // character followed
+	Region  math32.Box3    // pasture it stays in without UseNav, XZ only
+	Moving  bool           // walked during the last step
+}
+
+// FindAnimalProfile returns the profile of the stage file, if it is an animal
+func FindAnimalProfile(file string) (AnimalProfile, bool) {
+	base := strings.ToLower(filepath.Base(file))
+	for _, p := range animalProfiles {
+		if strings.HasPrefix(base, p.Kind) {
+			return p, true
+		}
+	}
+	return AnimalProfile{}, false
+}
+
+// addAnimal puts the animal of the stage at its start, resting,
+// facing the way its body was authored
+func (w *World) addAnimal(start AnimalStart) *Animal {
+	a := new(Animal)
+	a.Name = start.Name
+	a.Profile = start.Profile
+	a.Agent = NewAgent(start.Pos, start.Profile.Speed, steerAccel)
+	a.Dest = start.Pos
+	a.Heading = WrapAngle(start.Yaw + start.Profile.Forward)
+	w.restAnimal(a)
+	w.Space.Insert(&a.Body)
+	w.Animals = append(w.Animals, a)
+	log.Debug("Animal %v at %v", a.Name, start.Pos)
+	return a
+}
+
+// setupPastures gives the animals not using the nav grid their
+// pasture: the Pasture node or the area around their start.
+func (w *World) setupPastures() {
+	pasture, found := w.Stage.Pasture, w.Stage.HasPasture
+	if !found {
+		for _, a := range w.Animals {
+			if a.Profile.UseNav {
+				continue
+			}
+			pos := a.Pos()
+			if !found {
+				pasture = math32.Box3{Min: pos, Max: pos}
+				found = true
+			}
+			pasture.ExpandByPoint(&pos)
+		}
+		pasture.ExpandByScalar(pastureMargin)
+	}
+	for _, a := range w.Animals {
+		if !a.Profile.UseNav {
+			a.Region = pasture
+		}
+	}
+}
+
+// Animal returns the animal with the given name
+func (w *World) Animal(name string) (*Animal, bool) {
+	for _, a := range w.Animals {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// randDest returns a destination in the region of the animal
+func (w *World) randDest(a *Animal) math32.Vector3 {
+	if a.Profile.UseNav {
+		return w.randCoord()
+	}
+	r := a.Region
+	return math32.Vector3{
+		X: r.Min.X + w.Rng.Float32()*(r.Max.X-r.Min.X),
+		Z: r.Min.Z + w.Rng.Float32()*(r.Max.Z-r.Min.Z),
+	}
+}
+
+// restAnimal stops the animal for a while
+func (w *World) restAnimal(a *Animal) {
+	a.State = AnimalRest
+	a.Agent.Stop()
+	d := time.Duration(a.Profile.Rest.At(w.Rng.Float32()) * float32(time.Second))
+	a.Until = w.Clock.Add(d)
+}
+
+// updateAnimal moves the animal for delta seconds
+func (w *World) updateAnimal(a *Animal, delta float32) {
+	var nav *NavGrid
+	if a.Profile.UseNav {
+		nav = w.Stage.Nav
+	}
+	pos := a.Pos()
+	a.Moving = false
+
+	switch a.State {
+	case AnimalRest:
+		if w.Clock.Before(a.Until) {
+			break
+		}
+		if c, ok := w.nearestChar(pos); ok && w.Rng.Float32() < a.Profile.Follow {
+			log.Debug("%v follows %v", a.Name, c.Name)
+			a.State = AnimalFollow
+			a.Follow = c.ID
+			d := time.Duration(a.Profile.FollowFor.At(w.Rng.Float32()) * float32(time.Second))
+			a.Until = w.Clock.Add(d)
+			break
+		}
+		a.State = AnimalWalk
+		a.Dest = w.randDest(a)
+		a.Path = nil
+
+	case AnimalWalk:
+		stalled := pos.DistanceTo(&a.Dest) < slowRadius && a.Agent.Speed() < a.Agent.MaxSpeed*0.05
+		if pos.DistanceTo(&a.Dest) < animalTol || stalled {
+			w.restAnimal(a)
+			break
+		}
+		w.walkTo(&a.Body, a.Dest, nav, !a.Profile.UseNav, delta)
+		a.Moving = true
+
+	case AnimalFollow:
+		c, ok := w.Char(a.Follow)
+		if !ok || c.State == CharDespawning || !w.Clock.Before(a.Until) {
+			w.restAnimal(a)
+			break
+		}
+		// stay a bit behind on the side the animal comes from
+		cp := c.Pos()
+		away := pos
+		away.Sub(&cp).Normalize().MultiplyScalar(followDist)
+		a.Dest = cp
+		a.Dest.Add(&away)
+		if pos.DistanceTo(&cp) > followDist+animalTol {
+			w.walkTo(&a.Body, a.Dest, nav, false, delta)
+			a.Moving = true
+		} else {
+			a.Agent.Stop()
+			a.turn(HeadingTo(cp.X-pos.X, cp.Z-pos.Z), delta)
+		}
+	}
+}
+
+// nearestChar returns the closest active character within followRange
+func (w *World) nearestChar(pos math32.Vector3) (*Char, bool) {
+	b, ok := w.Space.Nearest(pos, followRange, func(b *Body) bool {
+		c, ok := w.Char(b.Char)
+		return ok && c.State == CharActive
+	})
+	if !ok {
+		return nil, false
+	}
+	return w.Char(b.Char)
+}
@@ -0,0 +1,81 @@
+package sim
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ARCHETYPE_MANIFEST is the optional file in charDir describing the archetypes
+const ARCHETYPE_MANIFEST string = "archetypes.json"
+
+// Archetype is a kind of character, one per gltf in charDir. It only
+// has what the simulation needs, the farm adds how it looks.
+type Archetype struct {
+	Name     string          `json:"name"`     // gltf base name: Father, Son, ...
+	Child    bool            `json:"child"`    // children follow the adults of their family
+	Traits   TraitLimits     `json:"traits"`   // limits of the per character variations
+	Schedule []ScheduleEntry `json:"schedule"` // activities liked, by farm hour
+}
+
+// LoadArchetypes scans charDir for gltf models. Models listed in the
+// manifest come first in its order with its settings, the others follow
+// in alphabetical order with default settings.
+func LoadArchetypes(charDir string) ([]Archetype, error) {
+	files, err := ioutil.ReadDir(charDir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	found := make(map[string]bool)
+	var names []string
+	for _, f := range files {
+		if filepath.Ext(f.Name()) == ".gltf" {
+			name := strings.TrimSuffix(f.Name(), ".gltf")
+			found[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var manifest []Archetype
+	data, err := ioutil.ReadFile(filepath.Join(charDir, ARCHETYPE_MANIFEST))
+	if err == nil {
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, errors.Wrap(err, "decoding "+ARCHETYPE_MANIFEST)
+		}
+	} else {
+		log.Debug("No archetype manifest: %v", err)
+	}
+
+	var archs []Archetype
+	listed := make(map[string]bool)
+	for _, arch := range manifest {
+		if !found[arch.Name] {
+			log.Debug("Archetype %v has no model in %v", arch.Name, charDir)
+			continue
+		}
+		listed[arch.Name] = true
+		archs = append(archs, arch)
+	}
+	for _, name := range names {
+		if !listed[name] {
+			archs = append(archs, Archetype{Name: name})
+		}
+	}
+	return archs, nil
+}
+
+// FindArchetype returns the archetype with the given name
+func (w *World) FindArchetype(name string) (Archetype, bool) {
+	for _, arch := range w.Archetypes {
+		if arch.Name == name {
+			return arch, true
+		}
+	}
+	return Archetype{}, false
+}
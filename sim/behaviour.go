@@ -0,0 +1,237 @@
+package sim
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/g3n/engine/math32"
+	"github.com/pkg/errors"
+)
+
+// BEHAVIOUR_FILENAME is the optional file in charDir with the behaviour config
+const BEHAVIOUR_FILENAME string = "behaviour.json"
+
+// BehaviourState is what a character is doing
+type BehaviourState string
+
+// The states a character goes through
+const (
+	StateWalk BehaviourState = "walk"
+	StateIdle BehaviourState = "idle"
+	StateLook BehaviourState = "look"
+	StatePet  BehaviourState = "pet"
+	StateWave BehaviourState = "wave"
+
+	// Activities at the points of interest
+	StateWork  BehaviourState = "work"
+	StateWatch BehaviourState = "watch"
+	StatePlay  BehaviourState = "play"
+	StateRest  BehaviourState = "rest"
+)
+
+// Events moving a character to another state
+const (
+	EventArrive     = "arrive"     // got to its destination
+	EventTimeout    = "timeout"    // the state duration is over
+	EventSeen       = "seen"       // its visitor is in front of the camera
+	EventNearAnimal = "nearAnimal" // got to its destination next to an animal
+)
+
+const (
+	petDist    = 1.5 // how close to an animal a character pets it
+	lookAngle  = 0.8 // how far a character looks left and right, radians
+	lookPeriod = 3.0 // seconds to look left and right
+)
+
+// Transition is a state that may follow, picked by weight
+type Transition struct {
+	State  BehaviourState `json:"state"`
+	Weight float32        `json:"weight"`
+}
+
+// StateConfig sets how long a state lasts and what comes after.
+// Walk has no duration, it ends when the character arrives.
+type StateConfig struct {
+	Duration Range        `json:"duration"` // seconds
+	Next     []Transition `json:"next"`
+}
+
+// BehaviourConfig is the state machine every character runs
+type BehaviourConfig struct {
+	States map[BehaviourState]StateConfig `json:"states"`
+	Events map[string]BehaviourState      `json:"events"` // state entered on an event
+}
+
+// DefaultBehaviour is used without behaviour.json.
+// After walking the idle trait decides whether the character rests.
+var DefaultBehaviour = BehaviourConfig{
+	States: map[BehaviourState]StateConfig{
+		StateWalk: {Next: []Transition{{StateIdle, 2}, {StateLook, 1}}},
+		StateIdle: {Duration: Range{2, 6}, Next: []Transition{{StateWalk, 3}, {StateLook, 1}}},
+		StateLook: {Duration: Range{2, 4}, Next: []Transition{{StateWalk, 1}}},
+		StatePet:  {Duration: Range{3, 6}, Next: []Transition{{StateWalk, 2}, {StateIdle, 1}}},
+		StateWave: {Duration: Range{1.5, 2.5}, Next: []Transition{{StateWalk, 1}}},
+
+		StateWork:  {Duration: Range{10, 20}, Next: []Transition{{StateWalk, 2}, {StateIdle, 1}}},
+		StateWatch: {Duration: Range{5, 10}, Next: []Transition{{StateWalk, 1}}},
+		StatePlay:  {Duration: Range{6, 12}, Next: []Transition{{StateWalk, 1}}},
+		StateRest:  {Duration: Range{8, 16}, Next: []Transition{{StateWalk, 1}}},
+	},
+	Events: map[string]BehaviourState{
+		EventSeen:       StateWave,
+		EventNearAnimal: StatePet,
+	},
+}
+
+// LoadBehaviour reads the behaviour config of charDir, or returns the default one
+func LoadBehaviour(charDir string) (*BehaviourConfig, error) {
+	data, err := ioutil.ReadFile(filepath.Join(charDir, BEHAVIOUR_FILENAME))
+	if err != nil {
+		log.Debug("No behaviour config: %v", err)
+		return &DefaultBehaviour, nil
+	}
+	conf := new(BehaviourConfig)
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, errors.Wrap(err, "decoding "+BEHAVIOUR_FILENAME)
+	}
+	if _, ok := conf.States[StateWalk]; !ok {
+		return nil, errors.New("decoding " + BEHAVIOUR_FILENAME + ": no walk state")
+	}
+	return conf, nil
+}
+
+// pick returns a state among next by weight
+func pick(next []Transition, u float32) (BehaviourState, bool) {
+	var total float32
+	for _, t := range next {
+		total += t.Weight
+	}
+	if total <= 0 {
+		return "", false
+	}
+	u *= total
+	for _, t := range next {
+		if u < t.Weight {
+			return t.State, true
+		}
+		u -= t.Weight
+	}
+	return next[len(next)-1].State, true
+}
+
+// enterState switches the character to state
+func (w *World) enterState(c *Char, state BehaviourState) {
+	conf, ok := w.Behaviour.States[state]
+	if !ok {
+		state, conf = StateWalk, w.Behaviour.States[StateWalk]
+	}
+	// leaving the activity frees the place
+	if c.Visit != nil && c.Behaviour == c.Visit.Activity && state != c.Behaviour {
+		w.leavePOI(c)
+	}
+	c.Behaviour = state
+	c.StateTime = 0
+	c.StateUntil = time.Time{}
+	if state != StateWalk {
+		d := time.Duration(conf.Duration.At(w.Rng.Float32()) * float32(time.Second))
+		c.StateUntil = w.Clock.Add(d)
+		c.Agent.Stop()
+	}
+	c.LookBase = c.Heading
+}
+
+// Event moves the character to the state configured for
+// the event, if any. Leaving characters keep walking out.
+func (w *World) Event(c *Char, event string) {
+	if c.State == CharDespawning || c.State == CharSpawning {
+		return
+	}
+	switch event {
+	case EventArrive:
+		if w.nearestAnimalDist(c.Pos()) < petDist {
+			if state, ok := w.Behaviour.Events[EventNearAnimal]; ok {
+				w.enterState(c, state)
+				return
+			}
+		}
+		// the idle trait says how often the character stops
+		if w.Rng.Float32() >= c.Traits.IdleFreq {
+			return
+		}
+		fallthrough
+	case EventTimeout:
+		next := w.Behaviour.States[c.Behaviour].Next
+		if state, ok := pick(next, w.Rng.Float32()); ok {
+			w.enterState(c, state)
+		} else {
+			w.enterState(c, StateWalk)
+		}
+	default:
+		if state, ok := w.Behaviour.Events[event]; ok && state != c.Behaviour {
+			w.enterState(c, state)
+		}
+	}
+}
+
+// updateBehaviour runs the current state for delta seconds,
+// it returns whether the character walks.
+func (w *World) updateBehaviour(c *Char, delta float32) bool {
+	if c.Behaviour == StateWalk {
+		return true
+	}
+	c.StateTime += delta
+	if !w.Clock.Before(c.StateUntil) {
+		w.Event(c, EventTimeout)
+		return c.Behaviour == StateWalk
+	}
+
+	cp := c.Pos()
+	switch c.Behaviour {
+	case StateLook:
+		if c.LookTurns {
+			// no clip, look around by turning on the spot
+			swing := math32.Sin(2 * math32.Pi * c.StateTime / lookPeriod)
+			c.turn(c.LookBase+swing*lookAngle, delta)
+		}
+	case StatePet:
+		if pos, ok := w.nearestAnimal(cp); ok {
+			c.turn(HeadingTo(pos.X-cp.X, pos.Z-cp.Z), delta)
+		}
+	case StateWave:
+		cam := w.Camera
+		c.turn(HeadingTo(cam.X-cp.X, cam.Z-cp.Z), delta)
+	default:
+		// visitors around a point of interest face it
+		if c.Visit != nil && c.Behaviour == c.Visit.Activity {
+			if p := c.Visit.Pos; p.DistanceTo(&cp) > poiSpread/2 {
+				c.turn(HeadingTo(p.X-cp.X, p.Z-cp.Z), delta)
+			}
+		}
+	}
+	return false
+}
+
+// nearestAnimal returns where the closest animal is
+func (w *World) nearestAnimal(pos math32.Vector3) (math32.Vector3, bool) {
+	var best math32.Vector3
+	found := false
+	for _, a := range w.Animals {
+		p := a.Pos()
+		p.Y = pos.Y
+		if !found || p.DistanceTo(&pos) < best.DistanceTo(&pos) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// nearestAnimalDist returns how far the closest animal is
+func (w *World) nearestAnimalDist(pos math32.Vector3) float32 {
+	p, ok := w.nearestAnimal(pos)
+	if !ok {
+		return math32.Infinity
+	}
+	return p.DistanceTo(&pos)
+}
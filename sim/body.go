@@ -0,0 +1,136 @@
+package sim
+
+import (
+	"github.com/g3n/engine/math32"
+)
+
+const (
+	turnRate    = 6   // radians per second
+	turnInPlace = 1.0 // radians off the way before turning on the spot
+	waypointTol = 0.3 // how close passing a waypoint counts
+)
+
+// Body is the movement characters and animals share: steering,
+// the path around the obstacles and where it faces.
+type Body struct {
+	Agent   Agent            // Steering state, Agent.Pos is where the body is
+	Path    []math32.Vector3 // Waypoints around the obstacles to the destination
+	PathTo  math32.Vector3   // Destination when Path was planned
+	Heading float32          // Yaw, radians
+	Char    CharID           // Character of the body, 0 for animals
+}
+
+// Pos returns where the body is
+func (b *Body) Pos() math32.Vector3 {
+	return b.Agent.Pos
+}
+
+// SetPos puts the body at pos, at rest
+func (b *Body) SetPos(pos math32.Vector3) {
+	b.Agent.Pos = pos
+	b.Agent.Stop()
+	b.Path = nil
+}
+
+// walkTo moves the body a step of delta seconds to dest: along its
+// path on nav, nil to go straight, slowing down at the end, away from
+// the others and clear of the obstacles. From a standstill it first
+// turns on the spot to face the way, and then returns false.
+func (w *World) walkTo(b *Body, dest math32.Vector3, nav *NavGrid, wander bool, delta float32) bool {
+	pos := b.Agent.Pos
+	target, last := b.nextWaypoint(dest, nav)
+
+	want := HeadingTo(target.X-pos.X, target.Z-pos.Z)
+	if b.Agent.Speed() < b.Agent.MaxSpeed*0.1 &&
+		math32.Abs(WrapAngle(want-b.Heading)) > turnInPlace {
+		b.turn(want, delta)
+		return false
+	}
+
+	var force math32.Vector3
+	if last {
+		force = b.Agent.Arrive(target)
+	} else {
+		force = b.Agent.Seek(target)
+	}
+	sep := b.Agent.Separate(w.neighbours(b))
+	force.Add(sep.MultiplyScalar(sepWeight))
+	if nav != nil {
+		avoid := b.Agent.AvoidObstacles(nav.Walkable)
+		force.Add(avoid.MultiplyScalar(avoidWeight))
+	}
+	if wander {
+		wand := b.Agent.Wander(w.Rng.Float32()*2-1, delta)
+		force.Add(wand.MultiplyScalar(wanderW))
+	}
+	b.Agent.Step(force, delta)
+	w.Space.Update(b)
+
+	if b.Agent.Speed() > 0 {
+		b.turn(HeadingTo(b.Agent.Vel.X, b.Agent.Vel.Z), delta)
+	}
+	return true
+}
+
+// nextWaypoint returns the point the body heads to now, on its path
+// around the obstacles to dest. The path is planned again when dest
+// moved. It also returns whether this is the last waypoint.
+func (b *Body) nextWaypoint(dest math32.Vector3, nav *NavGrid) (math32.Vector3, bool) {
+	if nav == nil {
+		return dest, true
+	}
+	pos := b.Agent.Pos
+	if b.Path == nil || b.PathTo.DistanceTo(&dest) > replanDist {
+		path, ok := nav.FindPath(pos, dest)
+		if !ok {
+			log.Debug("No path from %v to %v", pos, dest)
+			path = []math32.Vector3{dest}
+		}
+		b.Path = path
+		b.PathTo = dest
+	}
+
+	for len(b.Path) > 1 && pos.DistanceTo(&b.Path[0]) < waypointTol {
+		b.Path = b.Path[1:]
+	}
+	if len(b.Path) > 1 {
+		return b.Path[0], false
+	}
+	// The last waypoint follows the small moves of dest
+	if nav.Walkable(dest) {
+		return dest, true
+	}
+	return b.Path[0], true
+}
+
+// PathEnd returns where the path of the body ends, it is not
+// the destination when that one is in an obstacle.
+func (b *Body) PathEnd() (math32.Vector3, bool) {
+	if len(b.Path) == 0 {
+		return math32.Vector3{}, false
+	}
+	return b.Path[len(b.Path)-1], true
+}
+
+// turn turns the body to heading by turnRate at most
+func (b *Body) turn(heading, delta float32) {
+	turn := WrapAngle(heading - b.Heading)
+	if turn == 0 {
+		return
+	}
+	t := min(turnRate*delta/math32.Abs(turn), 1)
+	b.Heading = WrapAngle(b.Heading + turn*t)
+}
+
+// neighbours returns where the characters and animals close
+// enough to push b away are
+func (w *World) neighbours(b *Body) []math32.Vector3 {
+	var near []math32.Vector3
+	w.Space.Within(b.Agent.Pos, sepDist, func(other *Body, d float32) bool {
+		if other != b {
+			near = append(near, other.Agent.Pos)
+		}
+		return true
+	})
+	return near
+}
@@ -0,0 +1,238 @@
+package sim
+
+import (
+	"time"
+
+	"github.com/g3n/engine/math32"
+	"github.com/pkg/errors"
+)
+
+const (
+	walkSpeed  = 0.15 // units per second at pace 1
+	steerAccel = 6    // how many times walkSpeed the velocity changes per second
+	arriveTol  = 0.1  // how close to its destination a character has arrived
+	gateTol    = 0.3  // how close to the gate counts as arrived
+
+	// SpawnTime is how long a new character takes to grow in at the gate, seconds
+	SpawnTime = 0.8
+	// FadeTime is how long a leaving character takes to fade out at the gate, seconds
+	FadeTime = 1.0
+)
+
+// CharID is the unique and stable id of a character in the farm
+type CharID uint64
+
+// CharState is where the character is in its life cycle
+type CharState int
+
+const (
+	// CharActive is a character living on the farm
+	CharActive CharState = iota
+	// CharRemoved is a character that left the farm
+	CharRemoved
+	// CharSpawning is a character growing in at the gate
+	CharSpawning
+	// CharDespawning is a character walking out of the gate
+	CharDespawning
+)
+
+// Char is a character of the farm: who it is, what it does and where it goes
+type Char struct {
+	Body
+
+	ID        CharID    // Unique id in the world
+	Archetype string    // character Type: Son, Father, Mother, Daughter
+	Name      string    // Display name
+	Child     bool      // Children stay close to the adults of their group
+	Traits    Traits    // Size, pace and look of this character
+	State     CharState // Life cycle state
+	Created   time.Time // Time the character joined the farm
+	LastSeen  time.Time // Last time the face tracker saw the visitor
+	Pinned    bool      // Pinned characters are kept by the NeverEvictPinned policy
+	FaceHash  uint64    // Hash of the captured face
+
+	Behaviour  BehaviourState // What the character is doing
+	StateUntil time.Time      // End of the current state, zero when walking
+	StateTime  float32        // Seconds in the current state
+	LookBase   float32        // Heading when the state started
+	LookTurns  bool           // Looks around by turning, the model has no look clip
+
+	Dest    math32.Vector3 // The character current ongoing destination
+	Origin  math32.Vector3 // Where it walked from
+	Group   GroupID        // Family the character belongs to, 0 for none
+	Offset  math32.Vector3 // Place in the family formation
+	Visit   *POI           // Point of interest the character goes to or is at
+	Turning bool           // Turns on the spot before walking off
+
+	fx float32 // seconds into the spawn or the fade at the gate
+}
+
+// CharSpec describes a character to be added
+type CharSpec struct {
+	Archetype string  // Name of the Archetype
+	Name      string  // Display name, "" for a generated one
+	FaceHash  uint64  // Hash of the captured face
+	Group     GroupID // Family to join, 0 for none
+	Traits    Traits  // Traits of the character, generated when not set
+}
+
+// Grown returns how far the character grew in at the gate, from 0 to 1
+func (c *Char) Grown() float32 {
+	if c.State != CharSpawning {
+		return 1
+	}
+	return clamp(c.fx/SpawnTime, 0, 1)
+}
+
+// Faded returns how far the character faded out at the gate, from 0 to 1
+func (c *Char) Faded() float32 {
+	if c.State != CharDespawning {
+		return 0
+	}
+	return clamp(c.fx/FadeTime, 0, 1)
+}
+
+// AtGate returns whether the leaving character got to the gate
+func (c *Char) AtGate() bool {
+	pos := c.Pos()
+	return c.State == CharDespawning &&
+		almostEq(pos.X, c.Dest.X, gateTol) && almostEq(pos.Z, c.Dest.Z, gateTol)
+}
+
+// AddChar brings a new character in by the gate, growing in
+// for SpawnTime before it walks off.
+func (w *World) AddChar(spec CharSpec) (*Char, error) {
+	arch, ok := w.FindArchetype(spec.Archetype)
+	if !ok {
+		return nil, errors.Errorf("unknown archetype %q", spec.Archetype)
+	}
+	w.nextID++
+	c := &Char{ID: w.nextID, Archetype: arch.Name, Child: arch.Child, Name: spec.Name}
+	c.Body.Char = c.ID
+	if c.Name == "" {
+		c.Name = FarmName(w.Rng)
+	}
+	c.Traits = spec.Traits
+	if !c.Traits.Generated() {
+		c.Traits = arch.RandomTraits(spec.FaceHash)
+	}
+	c.Created = w.Clock
+	c.LastSeen = c.Created
+	c.FaceHash = spec.FaceHash
+
+	gate := w.Stage.Gate // everybody comes in by the gate
+	c.Origin = gate
+	c.Agent = NewAgent(gate, walkSpeed*c.Traits.Pace, steerAccel)
+	w.enterState(c, StateWalk)
+	c.Dest = w.randCoord()
+
+	w.chars[c.ID] = c
+	w.order = append(w.order, c.ID)
+	w.Space.Insert(&c.Body)
+	w.joinGroup(c, spec.Group)
+	c.State = CharSpawning
+	return c, nil
+}
+
+// Despawn sends the character walking out of the gate, it fades
+// out there and is removed. Use Remove to remove it at once.
+func (w *World) Despawn(id CharID) {
+	c, ok := w.Char(id)
+	if !ok || c.State == CharDespawning {
+		return
+	}
+	log.Debug("%v is leaving the farm", c.Name)
+	w.leaveGroup(c)
+	w.leavePOI(c)
+	c.State = CharDespawning
+	c.fx = 0
+	w.enterState(c, StateWalk)
+	c.Origin = c.Pos()
+	c.Dest = w.Stage.Gate
+	c.Path = nil
+}
+
+// Remove takes the character out of the world at once
+func (w *World) Remove(id CharID) {
+	c, ok := w.chars[id]
+	if !ok {
+		return
+	}
+	delete(w.chars, id)
+	for i, oid := range w.order {
+		if oid == id {
+			w.order = append(w.order[:i], w.order[i+1:]...)
+			break
+		}
+	}
+	w.leaveGroup(c)
+	w.leavePOI(c)
+	w.Space.Remove(&c.Body)
+	c.State = CharRemoved
+	if w.OnRemove != nil {
+		w.OnRemove(c)
+	}
+}
+
+// MoveChar walks the character for delta seconds to its destination
+// and picks the next one when it gets there.
+func (w *World) MoveChar(c *Char, delta float32) {
+	c.Turning = false
+	if c.State == CharSpawning || !w.updateBehaviour(c, delta) {
+		c.Agent.Stop()
+		return
+	}
+	leaving := c.State == CharDespawning
+	c.Agent.MaxSpeed = walkSpeed * c.Traits.Pace
+	c.Agent.MaxForce = c.Agent.MaxSpeed * steerAccel
+
+	// Followers head to their place around the leader target
+	// and wait there instead of picking their own destination
+	follower := w.followLeader(c)
+	pos := c.Pos()
+	stalled := pos.DistanceTo(&c.Dest) < slowRadius && c.Agent.Speed() < c.Agent.MaxSpeed*0.05
+	if (almostEq(pos.X, c.Dest.X, arriveTol) && almostEq(pos.Z, c.Dest.Z, arriveTol)) || (stalled && !leaving) {
+		c.Agent.Stop()
+		if follower || leaving {
+			return
+		}
+		c.Origin = c.Dest
+		if w.arriveAtPOI(c) {
+			return
+		}
+		c.Dest = w.nextDest(c)
+		c.Path = nil
+		w.Event(c, EventArrive)
+		return
+	}
+
+	c.Turning = !w.walkTo(&c.Body, c.Dest, w.Stage.Nav, !follower && !leaving, delta)
+
+	// Characters leaving by the gate or visiting a point of interest
+	// stop where the path ends, when their place is in an obstacle
+	if end, ok := c.PathEnd(); ok && (leaving || c.Visit != nil) && end != c.Dest {
+		c.Dest = end
+		c.PathTo = end
+	}
+}
+
+// updateGate grows in the spawning character and fades out the one
+// leaving once it is at the gate, it is removed when faded out.
+func (w *World) updateGate(c *Char, delta float32) {
+	switch c.State {
+	case CharSpawning:
+		c.fx += delta
+		if c.fx >= SpawnTime {
+			c.State = CharActive
+			c.fx = 0
+		}
+	case CharDespawning:
+		if !c.AtGate() {
+			return
+		}
+		c.fx += delta
+		if c.fx >= FadeTime {
+			w.Remove(c.ID)
+		}
+	}
+}
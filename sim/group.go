@@ -0,0 +1,131 @@
+package sim
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/g3n/engine/math32"
+)
+
+const (
+	adultSpread = 1.2 // how far adults walk from the shared target
+	childSpread = 0.6 // children stay closer
+	childLeash  = 2.0 // further than this from the leader, children catch up
+)
+
+// GroupID identifies a family, it is the start time of its capture session
+// so it stays unique across restarts
+type GroupID uint64
+
+// Group is a family of characters moving together, the leader wanders
+// and the other members follow in a loose formation
+type Group struct {
+	ID      GroupID
+	Leader  CharID
+	Members []CharID
+}
+
+// NewGroupID returns the id of a family whose session starts at when
+func NewGroupID(when time.Time) GroupID {
+	return GroupID(when.UnixNano())
+}
+
+// joinGroup adds the character to the group, creating it if needed
+func (w *World) joinGroup(c *Char, gid GroupID) {
+	if gid == 0 {
+		return
+	}
+	grp, ok := w.Groups[gid]
+	if !ok {
+		grp = &Group{ID: gid}
+		w.Groups[gid] = grp
+	}
+	c.Group = gid
+	c.Offset = formationOffset(c.Child, w.Rng)
+	grp.Members = append(grp.Members, c.ID)
+	w.electLeader(grp)
+}
+
+// leaveGroup takes the character out of its group
+func (w *World) leaveGroup(c *Char) {
+	grp, ok := w.Groups[c.Group]
+	if !ok {
+		return
+	}
+	for i, id := range grp.Members {
+		if id == c.ID {
+			grp.Members = append(grp.Members[:i], grp.Members[i+1:]...)
+			break
+		}
+	}
+	c.Group = 0
+	if len(grp.Members) == 0 {
+		delete(w.Groups, grp.ID)
+		return
+	}
+	w.electLeader(grp)
+}
+
+// electLeader makes the first adult the leader, or the first member
+// when the group has only children
+func (w *World) electLeader(grp *Group) {
+	grp.Leader = grp.Members[0]
+	for _, id := range grp.Members {
+		if c, ok := w.Char(id); ok && !c.Child {
+			grp.Leader = id
+			return
+		}
+	}
+}
+
+// GroupMembers returns the members of the family
+func (w *World) GroupMembers(gid GroupID) []CharID {
+	grp, ok := w.Groups[gid]
+	if !ok {
+		return nil
+	}
+	return append([]CharID(nil), grp.Members...)
+}
+
+// Leader returns the leader of the character group, if it has a group
+func (w *World) Leader(c *Char) (*Char, bool) {
+	grp, ok := w.Groups[c.Group]
+	if !ok {
+		return nil, false
+	}
+	return w.Char(grp.Leader)
+}
+
+// followLeader points a follower to its place around the leader target,
+// children left too far behind head to the leader itself.
+// It returns false for leaders and characters without group.
+func (w *World) followLeader(c *Char) bool {
+	leader, ok := w.Leader(c)
+	if !ok || leader == c {
+		return false
+	}
+
+	pos := c.Pos()
+	target := leader.Dest
+	if c.Child {
+		leaderPos := leader.Pos()
+		if pos.DistanceTo(&leaderPos) > childLeash {
+			target = leaderPos
+		}
+	}
+	c.Dest = target
+	c.Dest.Add(&c.Offset)
+	c.Origin = pos
+	return true
+}
+
+// formationOffset returns a random place around the group target
+func formationOffset(child bool, rng *rand.Rand) math32.Vector3 {
+	spread := float32(adultSpread)
+	if child {
+		spread = childSpread
+	}
+	angle := rng.Float32() * 2 * math32.Pi
+	dist := spread * (0.5 + rng.Float32()/2)
+	return math32.Vector3{X: dist * math32.Cos(angle), Z: dist * math32.Sin(angle)}
+}
@@ -0,0 +1,17 @@
+package sim
+
+import "math/rand"
+
+// Farm themed names for characters whose visitor typed nothing
+var (
+	farmFirstNames = []string{"Barley", "Clover", "Daisy", "Hazel", "Maple",
+		"Oats", "Pumpkin", "Rusty", "Sunny", "Turnip", "Willow", "Acorn"}
+	farmLastNames = []string{"Haybale", "Cornfield", "Pitchfork", "Meadow",
+		"Barnes", "Furrow", "Orchard", "Wheatley", "Cobb", "Thistle"}
+)
+
+// FarmName returns a generated farm themed name
+func FarmName(rng *rand.Rand) string {
+	return farmFirstNames[rng.Intn(len(farmFirstNames))] + " " +
+		farmLastNames[rng.Intn(len(farmLastNames))]
+}
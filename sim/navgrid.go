@@ -0,0 +1,258 @@
+package sim
+
+import (
+	"container/heap"
+
+	"github.com/g3n/engine/math32"
+)
+
+const (
+	navCell      = 0.25 // size of a grid cell
+	navClearance = 0.3  // half the width of a character, obstacles grow by it
+	navHeadroom  = 1.5  // obstacles starting higher than this are walked under
+	replanDist   = 0.5  // followers replan when their target moved that much
+)
+
+// NavGrid is a walkable grid over the XZ plane of the stage
+type NavGrid struct {
+	Min     math32.Vector3 // corner of the cell 0,0
+	Cell    float32
+	W, H    int
+	blocked []bool
+}
+
+// NewNavGrid returns an all walkable *NavGrid covering bounds
+func NewNavGrid(bounds math32.Box3, cell float32) *NavGrid {
+	g := new(NavGrid)
+	g.Min = bounds.Min
+	g.Cell = cell
+	g.W = int(math32.Ceil((bounds.Max.X - bounds.Min.X) / cell))
+	g.H = int(math32.Ceil((bounds.Max.Z - bounds.Min.Z) / cell))
+	g.blocked = make([]bool, g.W*g.H)
+	return g
+}
+
+// cellOf returns the cell containing p
+func (g *NavGrid) cellOf(p math32.Vector3) (int, int) {
+	return int(math32.Floor((p.X - g.Min.X) / g.Cell)),
+		int(math32.Floor((p.Z - g.Min.Z) / g.Cell))
+}
+
+// center returns the middle of the cell i,j on the ground
+func (g *NavGrid) center(i, j int) math32.Vector3 {
+	return math32.Vector3{
+		X: g.Min.X + (float32(i)+0.5)*g.Cell,
+		Z: g.Min.Z + (float32(j)+0.5)*g.Cell,
+	}
+}
+
+func (g *NavGrid) inside(i, j int) bool {
+	return i >= 0 && j >= 0 && i < g.W && j < g.H
+}
+
+// open returns whether the cell i,j is in the grid and walkable
+func (g *NavGrid) open(i, j int) bool {
+	return g.inside(i, j) && !g.blocked[j*g.W+i]
+}
+
+// Walkable returns whether p is on a walkable cell
+func (g *NavGrid) Walkable(p math32.Vector3) bool {
+	return g.open(g.cellOf(p))
+}
+
+// Block marks the cells under the box grown by margin
+func (g *NavGrid) Block(box math32.Box3, margin float32) {
+	i0, j0 := g.cellOf(math32.Vector3{X: box.Min.X - margin, Z: box.Min.Z - margin})
+	i1, j1 := g.cellOf(math32.Vector3{X: box.Max.X + margin, Z: box.Max.Z + margin})
+	for j := j0; j <= j1; j++ {
+		for i := i0; i <= i1; i++ {
+			if g.inside(i, j) {
+				g.blocked[j*g.W+i] = true
+			}
+		}
+	}
+}
+
+// nearestOpen returns the walkable cell closest to i,j
+func (g *NavGrid) nearestOpen(i, j int) (int, int, bool) {
+	if g.open(i, j) {
+		return i, j, true
+	}
+	// grow rings around the cell until one has a walkable cell
+	for r := 1; r < g.W+g.H; r++ {
+		best, bi, bj := -1, 0, 0
+		for dj := -r; dj <= r; dj++ {
+			for di := -r; di <= r; di++ {
+				if (di != -r && di != r && dj != -r && dj != r) || !g.open(i+di, j+dj) {
+					continue
+				}
+				if d := di*di + dj*dj; best < 0 || d < best {
+					best, bi, bj = d, i+di, j+dj
+				}
+			}
+		}
+		if best >= 0 {
+			return bi, bj, true
+		}
+	}
+	return 0, 0, false
+}
+
+// snap returns p when it is walkable, else the center of the closest
+// walkable cell. It returns false when no cell is walkable.
+func (g *NavGrid) snap(p math32.Vector3) (math32.Vector3, bool) {
+	if g.Walkable(p) {
+		return p, true
+	}
+	i, j := g.cellOf(p)
+	i, j, ok := g.nearestOpen(i, j)
+	if !ok {
+		return p, false
+	}
+	return g.center(i, j), true
+}
+
+// clear returns whether the straight line from a to b only crosses
+// walkable cells
+func (g *NavGrid) clear(a, b math32.Vector3) bool {
+	dist := math32.Sqrt((b.X-a.X)*(b.X-a.X) + (b.Z-a.Z)*(b.Z-a.Z))
+	steps := int(dist/(g.Cell/2)) + 1
+	for s := 0; s <= steps; s++ {
+		t := float32(s) / float32(steps)
+		p := math32.Vector3{X: a.X + (b.X-a.X)*t, Z: a.Z + (b.Z-a.Z)*t}
+		if !g.Walkable(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// navNode is a cell in the A* open set
+type navNode struct {
+	cell  int
+	f     float32
+	index int
+}
+
+// navQueue is a min heap of navNodes on f
+type navQueue []*navNode
+
+func (q navQueue) Len() int            { return len(q) }
+func (q navQueue) Less(a, b int) bool  { return q[a].f < q[b].f }
+func (q navQueue) Swap(a, b int)       { q[a], q[b] = q[b], q[a]; q[a].index = a; q[b].index = b }
+func (q *navQueue) Push(x interface{}) { n := x.(*navNode); n.index = len(*q); *q = append(*q, n) }
+func (q *navQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// FindPath returns the waypoints from from to to around the obstacles,
+// from excluded. A goal in an obstacle is moved to the closest walkable
+// cell. It returns false when to cannot be reached.
+func (g *NavGrid) FindPath(from, to math32.Vector3) ([]math32.Vector3, bool) {
+	si, sj := g.cellOf(from)
+	si, sj, ok := g.nearestOpen(si, sj)
+	if !ok {
+		return nil, false
+	}
+	gi, gj := g.cellOf(to)
+	gi, gj, ok = g.nearestOpen(gi, gj)
+	if !ok {
+		return nil, false
+	}
+	goal := to
+	if !g.Walkable(to) {
+		goal = g.center(gi, gj)
+	}
+	goal.Y = 0
+	if g.Walkable(from) && g.clear(from, goal) {
+		return []math32.Vector3{goal}, true
+	}
+
+	start, end := sj*g.W+si, gj*g.W+gi
+	cost := map[int]float32{start: 0}
+	came := map[int]int{}
+	h := func(c int) float32 {
+		dx := float32(c%g.W - gi)
+		dz := float32(c/g.W - gj)
+		return math32.Sqrt(dx*dx + dz*dz)
+	}
+	open := &navQueue{{cell: start, f: h(start)}}
+	closed := map[int]bool{}
+	for open.Len() > 0 {
+		cur := heap.Pop(open).(*navNode).cell
+		if cur == end {
+			return g.smooth(from, g.trace(came, start, end), goal), true
+		}
+		if closed[cur] {
+			continue
+		}
+		closed[cur] = true
+		ci, cj := cur%g.W, cur/g.W
+		for dj := -1; dj <= 1; dj++ {
+			for di := -1; di <= 1; di++ {
+				ni, nj := ci+di, cj+dj
+				if (di == 0 && dj == 0) || !g.open(ni, nj) {
+					continue
+				}
+				// no cutting corners around obstacles
+				if di != 0 && dj != 0 && (!g.open(ci+di, cj) || !g.open(ci, cj+dj)) {
+					continue
+				}
+				step := float32(1)
+				if di != 0 && dj != 0 {
+					step = math32.Sqrt(2)
+				}
+				next := nj*g.W + ni
+				c := cost[cur] + step
+				if old, seen := cost[next]; seen && old <= c {
+					continue
+				}
+				cost[next] = c
+				came[next] = cur
+				heap.Push(open, &navNode{cell: next, f: c + h(next)})
+			}
+		}
+	}
+	return nil, false
+}
+
+// trace returns the cell centers from start to end, start excluded
+func (g *NavGrid) trace(came map[int]int, start, end int) []math32.Vector3 {
+	var path []math32.Vector3
+	for c := end; c != start; c = came[c] {
+		path = append(path, g.center(c%g.W, c/g.W))
+	}
+	for a, b := 0, len(path)-1; a < b; a, b = a+1, b-1 {
+		path[a], path[b] = path[b], path[a]
+	}
+	return path
+}
+
+// smooth drops the waypoints that can be skipped in a straight line
+// and ends the path on goal
+func (g *NavGrid) smooth(from math32.Vector3, path []math32.Vector3, goal math32.Vector3) []math32.Vector3 {
+	if len(path) > 0 {
+		path[len(path)-1] = goal
+	} else {
+		path = append(path, goal)
+	}
+	var out []math32.Vector3
+	at := from
+	for i := 0; i < len(path); {
+		// furthest waypoint in sight
+		next := i
+		for k := len(path) - 1; k > i; k-- {
+			if g.clear(at, path[k]) {
+				next = k
+				break
+			}
+		}
+		out = append(out, path[next])
+		at = path[next]
+		i = next + 1
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package sim
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+// newTestGrid returns a 10 by 10 grid of unit cells
+func newTestGrid() *NavGrid {
+	return NewNavGrid(math32.Box3{Max: math32.Vector3{X: 10, Y: 1, Z: 10}}, 1)
+}
+
+// checkPath fails unless path walks from from to goal in straight
+// walkable lines
+func checkPath(t *testing.T, g *NavGrid, from, goal math32.Vector3, path []math32.Vector3) {
+	t.Helper()
+	if len(path) == 0 {
+		t.Fatal("empty path")
+	}
+	if end := path[len(path)-1]; end != goal {
+		t.Errorf("path ends at %v, want %v", end, goal)
+	}
+	at := from
+	for _, p := range path {
+		if !g.clear(at, p) {
+			t.Errorf("path %v crosses an obstacle from %v to %v", path, at, p)
+		}
+		at = p
+	}
+}
+
+func TestFindPathAroundBlock(t *testing.T) {
+	g := newTestGrid()
+	// a wall across column 4 with a way round at the top
+	g.Block(math32.Box3{Min: math32.Vector3{X: 4.2}, Max: math32.Vector3{X: 4.8, Z: 7.8}}, 0)
+	from, to := math32.Vector3{X: 1.5, Z: 1.5}, math32.Vector3{X: 8.5, Z: 1.5}
+	if g.clear(from, to) {
+		t.Fatal("the wall does not block the straight line")
+	}
+	path, ok := g.FindPath(from, to)
+	if !ok {
+		t.Fatal("FindPath found no way around the wall")
+	}
+	if len(path) < 2 {
+		t.Errorf("path %v goes straight through the wall", path)
+	}
+	checkPath(t, g, from, to, path)
+	for _, p := range path {
+		if p.Z < 8 && almostEq(p.X, 4.5, 0.5) {
+			t.Errorf("waypoint %v is in the wall", p)
+		}
+	}
+
+	// a goal in the wall moves to the closest walkable cell
+	path, ok = g.FindPath(from, math32.Vector3{X: 4.5, Z: 1.5})
+	if !ok {
+		t.Fatal("FindPath to a goal in the wall failed")
+	}
+	if end := path[len(path)-1]; !g.Walkable(end) {
+		t.Errorf("path to a goal in the wall ends in it at %v", end)
+	}
+}
+
+func TestFindPathUnreachable(t *testing.T) {
+	g := newTestGrid()
+	// a wall right across the grid
+	g.Block(math32.Box3{Min: math32.Vector3{X: 4.2}, Max: math32.Vector3{X: 4.8, Z: 10}}, 0)
+	if path, ok := g.FindPath(math32.Vector3{X: 1.5, Z: 1.5}, math32.Vector3{X: 8.5, Z: 1.5}); ok {
+		t.Errorf("FindPath through a wall = %v, want none", path)
+	}
+
+	// nowhere is walkable
+	g.Block(math32.Box3{Max: math32.Vector3{X: 10, Z: 10}}, 0)
+	if path, ok := g.FindPath(math32.Vector3{X: 1.5, Z: 1.5}, math32.Vector3{X: 2.5, Z: 1.5}); ok {
+		t.Errorf("FindPath on a blocked grid = %v, want none", path)
+	}
+}
+
+func TestFindPathStartIsGoal(t *testing.T) {
+	g := newTestGrid()
+	g.Block(math32.Box3{Min: math32.Vector3{X: 4.2}, Max: math32.Vector3{X: 4.8, Z: 7.8}}, 0)
+	at := math32.Vector3{X: 1.5, Z: 1.5}
+	path, ok := g.FindPath(at, at)
+	if !ok {
+		t.Fatal("FindPath to where it stands failed")
+	}
+	if len(path) != 1 || path[0] != at {
+		t.Errorf("FindPath to where it stands = %v, want [%v]", path, at)
+	}
+}
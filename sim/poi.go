@@ -0,0 +1,168 @@
+package sim
+
+import (
+	"time"
+
+	"github.com/g3n/engine/math32"
+)
+
+// POIS_FILENAME is the optional file in stageDir setting up the points of interest
+const POIS_FILENAME string = "pois.json"
+
+const (
+	poiTag    = "POI_"
+	poiSpread = 0.6              // how far around the point its visitors stand
+	dayLength = 12 * time.Minute // how long a farm day lasts
+	dawnHour  = 7.0              // farm hour when the farm opens
+)
+
+// POIConfig sets what a point of interest is for, POI_<Activity>_<Name>
+// nodes do their activity for one visitor without it.
+type POIConfig struct {
+	Activity BehaviourState `json:"activity"`
+	Capacity int            `json:"capacity"`
+}
+
+// POI is a place of the stage characters visit for an activity
+type POI struct {
+	Name     string
+	Activity BehaviourState // state the visitors are in there
+	Pos      math32.Vector3
+	slots    []CharID         // visitor at each place around Pos, 0 when free
+	places   []math32.Vector3 // where the visitor of each place stands
+}
+
+// ScheduleEntry is an activity an archetype likes during some farm hours
+type ScheduleEntry struct {
+	Activity BehaviourState `json:"activity"` // walk wanders around
+	Weight   float32        `json:"weight"`
+	Hours    Range          `json:"hours"` // all day when zero
+}
+
+// free returns a free place at the point, -1 when it is full
+func (poi *POI) free() int {
+	for i, id := range poi.slots {
+		if id == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// newPOI returns a *POI for capacity visitors, standing around pos
+// when it takes several
+func newPOI(name string, activity BehaviourState, pos math32.Vector3, capacity int) *POI {
+	poi := &POI{Name: name, Activity: activity, Pos: pos}
+	for i := 0; i < capacity; i++ {
+		place := pos
+		if capacity > 1 {
+			a := 2 * math32.Pi * float32(i) / float32(capacity)
+			place.X += poiSpread * math32.Cos(a)
+			place.Z += poiSpread * math32.Sin(a)
+		}
+		poi.slots = append(poi.slots, 0)
+		poi.places = append(poi.places, place)
+	}
+	return poi
+}
+
+// slotPos returns where the visitor of place i stands
+func (poi *POI) slotPos(i int) math32.Vector3 {
+	return poi.places[i]
+}
+
+// snapPlaces moves the places in obstacles to the closest walkable
+// cell of nav and drops the ones without any. It returns whether
+// the point has a place left.
+func (poi *POI) snapPlaces(nav *NavGrid) bool {
+	var places []math32.Vector3
+	for _, p := range poi.places {
+		snapped, ok := nav.snap(p)
+		if !ok {
+			log.Debug("%v: no walkable place near %v, dropped", poi.Name, p)
+			continue
+		}
+		if snapped != p {
+			log.Debug("%v: place %v is in an obstacle, moved to %v", poi.Name, p, snapped)
+		}
+		places = append(places, snapped)
+	}
+	poi.places = places
+	poi.slots = make([]CharID, len(places))
+	return len(places) > 0
+}
+
+// FarmHour returns the hour of the farm day, from 0 to 24
+func (w *World) FarmHour() float32 {
+	days := w.Clock.Sub(w.Opened).Hours() / dayLength.Hours()
+	return math32.Mod(dawnHour+float32(days)*24, 24)
+}
+
+// nextDest picks what the character does next from the schedule of its
+// archetype: a free point of interest for one of the activities liked at
+// this hour, or a random place to walk to.
+func (w *World) nextDest(c *Char) math32.Vector3 {
+	w.leavePOI(c)
+	arch, _ := w.FindArchetype(c.Archetype)
+	hour := w.FarmHour()
+
+	var options []Transition
+	for _, e := range arch.Schedule {
+		if e.Hours != (Range{}) && (hour < e.Hours[0] || hour >= e.Hours[1]) {
+			continue
+		}
+		if e.Activity == StateWalk || len(w.freePOIs(e.Activity)) > 0 {
+			options = append(options, Transition{e.Activity, e.Weight})
+		}
+	}
+	activity, ok := pick(options, w.Rng.Float32())
+	if !ok || activity == StateWalk {
+		return w.randCoord()
+	}
+
+	free := w.freePOIs(activity)
+	poi := free[w.Rng.Intn(len(free))]
+	slot := poi.free()
+	poi.slots[slot] = c.ID
+	c.Visit = poi
+	log.Debug("%v goes to %v for %v at %.1fh", c.Name, poi.Name, activity, hour)
+	return poi.slotPos(slot)
+}
+
+// freePOIs returns the points of interest for activity with a free place
+func (w *World) freePOIs(activity BehaviourState) []*POI {
+	var free []*POI
+	for _, poi := range w.Stage.POIs {
+		if poi.Activity == activity && poi.free() >= 0 {
+			free = append(free, poi)
+		}
+	}
+	return free
+}
+
+// arriveAtPOI starts the activity of the point the character walked to,
+// it returns false when the character is not visiting one.
+func (w *World) arriveAtPOI(c *Char) bool {
+	if c.Visit == nil || c.Behaviour != StateWalk {
+		return false
+	}
+	w.enterState(c, c.Visit.Activity)
+	if c.Behaviour != c.Visit.Activity {
+		w.leavePOI(c)
+		return false
+	}
+	return true
+}
+
+// leavePOI frees the place of the character at the point it visits
+func (w *World) leavePOI(c *Char) {
+	if c.Visit == nil {
+		return
+	}
+	for i, id := range c.Visit.slots {
+		if id == c.ID {
+			c.Visit.slots[i] = 0
+		}
+	}
+	c.Visit = nil
+}
@@ -0,0 +1,105 @@
+package sim
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/g3n/engine/math32"
+)
+
+// spawnGap is the seconds between the characters Run spawns
+const spawnGap = 2.0
+
+// Sample is where a character or animal is at a tick of a run
+type Sample struct {
+	Tick    int     `json:"tick"`
+	Time    float32 `json:"time"` // seconds since the start
+	Kind    string  `json:"kind"` // char or animal
+	ID      CharID  `json:"id"`   // 0 for animals
+	Name    string  `json:"name"`
+	X       float32 `json:"x"`
+	Z       float32 `json:"z"`
+	Heading float32 `json:"heading"`
+	Speed   float32 `json:"speed"`
+	DestX   float32 `json:"destX"`
+	DestZ   float32 `json:"destZ"`
+	State   string  `json:"state"` // behaviour of characters, state of animals
+	POI     string  `json:"poi"`   // point of interest visited, if any
+}
+
+// Trajectories are the samples of every tick of a run
+type Trajectories struct {
+	Step    float32  `json:"step"` // seconds per tick
+	Ticks   int      `json:"ticks"`
+	Samples []Sample `json:"samples"`
+}
+
+// Run steps the world ticks times by step seconds, spawning up to
+// maxChars characters of the archetypes in turn spawnGap seconds apart,
+// and returns where every character and animal is at each tick.
+func (w *World) Run(ticks int, step float32, maxChars int) *Trajectories {
+	run := &Trajectories{Step: step, Ticks: ticks}
+	spawned := 0
+	for tick := 0; tick < ticks; tick++ {
+		t := float32(tick) * step
+		if spawned < maxChars && len(w.Archetypes) > 0 && t >= float32(spawned)*spawnGap {
+			arch := w.Archetypes[spawned%len(w.Archetypes)]
+			if _, err := w.AddChar(CharSpec{Archetype: arch.Name, FaceHash: w.Rng.Uint64()}); err != nil {
+				log.Error("Error adding character: %v", err)
+			}
+			spawned++
+		}
+		w.Step(step)
+		run.Samples = w.sample(run.Samples, tick, t+step)
+	}
+	return run
+}
+
+// sample appends where the characters and animals are to samples
+func (w *World) sample(samples []Sample, tick int, t float32) []Sample {
+	for _, c := range w.Chars() {
+		s := Sample{Tick: tick, Time: t, Kind: "char", ID: c.ID, Name: c.Name,
+			State: string(c.Behaviour)}
+		s.setBody(&c.Body, c.Dest)
+		if c.State == CharDespawning {
+			s.State = "leave"
+		}
+		if c.Visit != nil {
+			s.POI = c.Visit.Name
+		}
+		samples = append(samples, s)
+	}
+	for _, a := range w.Animals {
+		s := Sample{Tick: tick, Time: t, Kind: "animal", Name: a.Name, State: a.State.String()}
+		s.setBody(&a.Body, a.Dest)
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// setBody fills the movement of the sample
+func (s *Sample) setBody(b *Body, dest math32.Vector3) {
+	pos := b.Pos()
+	s.X, s.Z = pos.X, pos.Z
+	s.Heading = b.Heading
+	s.Speed = b.Agent.Speed()
+	s.DestX, s.DestZ = dest.X, dest.Z
+}
+
+// WriteCSV writes the samples with a header line
+func (run *Trajectories) WriteCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"tick", "time", "kind", "id", "name", "x", "z",
+		"heading", "speed", "destX", "destZ", "state", "poi"})
+	num := func(v float32) string {
+		return strconv.FormatFloat(float64(v), 'f', 3, 32)
+	}
+	for _, s := range run.Samples {
+		w.Write([]string{strconv.Itoa(s.Tick), num(s.Time), s.Kind, fmt.Sprint(s.ID), s.Name,
+			num(s.X), num(s.Z), num(s.Heading), num(s.Speed), num(s.DestX), num(s.DestZ), s.State, s.POI})
+	}
+	w.Flush()
+	return w.Error()
+}
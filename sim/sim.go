@@ -0,0 +1,79 @@
+// Package sim is the farm without renderer: where the characters and
+// animals are, where they walk to and what they do. It only needs the
+// assets files, the farm views copy the positions to their g3n nodes
+// after every Step and cmd/farmsim runs it headless.
+package sim
+
+import (
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/util/logger"
+)
+
+// log is the logger of the simulation, see SetLogger
+var log = logger.Default
+
+// SetLogger makes the simulation log to l
+func SetLogger(l *logger.Logger) {
+	log = l
+}
+
+// Range is a [min, max] interval
+type Range [2]float32
+
+// At returns the value at u within the range, u going from 0 to 1
+func (r Range) At(u float32) float32 {
+	return r[0] + u*(r[1]-r[0])
+}
+
+func min(x, y float32) float32 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+func max(x, y float32) float32 {
+	if x > y {
+		return x
+	}
+	return y
+}
+
+// clamp returns v within lo and hi
+func clamp(v, lo, hi float32) float32 {
+	return min(max(v, lo), hi)
+}
+
+// almostEq returns whether a and b are closer than tolerance
+func almostEq(a, b, tolerance float32) bool {
+	return math32.Abs(a-b) < tolerance
+}
+
+// WrapAngle returns a in [-Pi, Pi]
+func WrapAngle(a float32) float32 {
+	for a > math32.Pi {
+		a -= 2 * math32.Pi
+	}
+	for a < -math32.Pi {
+		a += 2 * math32.Pi
+	}
+	return a
+}
+
+// HeadingTo returns the model heading walking along dx, dz,
+// models face +X
+func HeadingTo(dx, dz float32) float32 {
+	return math32.Atan2(dx, dz) - math32.Pi/2
+}
+
+// HeadingDir returns the way a model with the heading walks,
+// the inverse of HeadingTo
+func HeadingDir(heading float32) math32.Vector3 {
+	return math32.Vector3{X: math32.Cos(heading), Z: -math32.Sin(heading)}
+}
+
+// Yaw returns the angle q turns around the vertical axis, the twist of
+// its swing-twist decomposition, radians
+func Yaw(q math32.Quaternion) float32 {
+	return WrapAngle(2 * math32.Atan2(q.Y, q.W))
+}
@@ -0,0 +1,29 @@
+package sim
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestHeadingDir(t *testing.T) {
+	for _, d := range []math32.Vector3{{X: 1}, {Z: 1}, {X: -1}, {X: 0.6, Z: -0.8}, {X: -0.28, Z: 0.96}} {
+		got := HeadingDir(HeadingTo(d.X, d.Z))
+		if !nearVec(got, d, 1e-5) {
+			t.Errorf("HeadingDir(HeadingTo(%v)) = %v", d, got)
+		}
+	}
+}
+
+func TestYaw(t *testing.T) {
+	for _, yaw := range []float32{0, 0.5, -2, 3} {
+		var turn, tilt, q math32.Quaternion
+		turn.SetFromAxisAngle(&math32.Vector3{Y: 1}, yaw)
+		// a tilt after the turn does not change the yaw
+		tilt.SetFromAxisAngle(&math32.Vector3{X: 1}, 0.7)
+		q.MultiplyQuaternions(&turn, &tilt)
+		if got := Yaw(q); !almostEq(got, yaw, 1e-5) {
+			t.Errorf("Yaw of a %v turn = %v", yaw, got)
+		}
+	}
+}
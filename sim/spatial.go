@@ -0,0 +1,125 @@
+package sim
+
+import (
+	"github.com/g3n/engine/math32"
+)
+
+// spaceCell is the size of a spatial hash cell, about the distance
+// bodies keep from each other
+const spaceCell = 1.0
+
+// cellKey is the column and row of a spatial hash cell
+type cellKey struct{ i, j int }
+
+// SpatialHash indexes the bodies by where they are on the XZ plane, so
+// neighbour queries only look at the cells around instead of everybody.
+// Bodies are moved to their new cell with Update as they walk.
+type SpatialHash struct {
+	Cell  float32
+	cells map[cellKey][]*Body
+	where map[*Body]cellKey
+}
+
+// NewSpatialHash returns an empty *SpatialHash of cell sized cells
+func NewSpatialHash(cell float32) *SpatialHash {
+	return &SpatialHash{
+		Cell:  cell,
+		cells: make(map[cellKey][]*Body),
+		where: make(map[*Body]cellKey),
+	}
+}
+
+// key returns the cell containing p
+func (h *SpatialHash) key(p math32.Vector3) cellKey {
+	return cellKey{int(math32.Floor(p.X / h.Cell)), int(math32.Floor(p.Z / h.Cell))}
+}
+
+// Len returns how many bodies are indexed
+func (h *SpatialHash) Len() int {
+	return len(h.where)
+}
+
+// Insert indexes w where it is
+func (h *SpatialHash) Insert(w *Body) {
+	if _, ok := h.where[w]; ok {
+		h.Update(w)
+		return
+	}
+	k := h.key(w.Agent.Pos)
+	h.where[w] = k
+	h.cells[k] = append(h.cells[k], w)
+}
+
+// Remove takes w out of the index
+func (h *SpatialHash) Remove(w *Body) {
+	k, ok := h.where[w]
+	if !ok {
+		return
+	}
+	delete(h.where, w)
+	h.unlink(w, k)
+}
+
+// Update moves w to the cell it is in now
+func (h *SpatialHash) Update(w *Body) {
+	old, ok := h.where[w]
+	if !ok {
+		return
+	}
+	k := h.key(w.Agent.Pos)
+	if k == old {
+		return
+	}
+	h.unlink(w, old)
+	h.where[w] = k
+	h.cells[k] = append(h.cells[k], w)
+}
+
+// unlink removes w from the bodies of cell k
+func (h *SpatialHash) unlink(w *Body, k cellKey) {
+	cell := h.cells[k]
+	for i, other := range cell {
+		if other == w {
+			cell[i] = cell[len(cell)-1]
+			cell = cell[:len(cell)-1]
+			break
+		}
+	}
+	if len(cell) == 0 {
+		delete(h.cells, k)
+	} else {
+		h.cells[k] = cell
+	}
+}
+
+// Within calls fn with the bodies closer than r to p, and their
+// distance, until fn returns false.
+func (h *SpatialHash) Within(p math32.Vector3, r float32, fn func(w *Body, d float32) bool) {
+	p.Y = 0
+	lo := h.key(math32.Vector3{X: p.X - r, Z: p.Z - r})
+	hi := h.key(math32.Vector3{X: p.X + r, Z: p.Z + r})
+	for i := lo.i; i <= hi.i; i++ {
+		for j := lo.j; j <= hi.j; j++ {
+			for _, w := range h.cells[cellKey{i, j}] {
+				pos := w.Agent.Pos
+				pos.Y = 0
+				if d := pos.DistanceTo(&p); d < r && !fn(w, d) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Nearest returns the closest body to p within r that match accepts
+func (h *SpatialHash) Nearest(p math32.Vector3, r float32, match func(w *Body) bool) (*Body, bool) {
+	var near *Body
+	best := r
+	h.Within(p, r, func(w *Body, d float32) bool {
+		if d < best && match(w) {
+			near, best = w, d
+		}
+		return true
+	})
+	return near, near != nil
+}
@@ -0,0 +1,206 @@
+package sim
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+// benchSizes are the numbers of bodies the benchmarks measure
+var benchSizes = []int{50, 200, 1000}
+
+// benchDensity is the ground per body in the benchmarks, square units
+const benchDensity = 2.0
+
+// newBody returns a body at x,z
+func newBody(x, z float32) *Body {
+	b := &Body{}
+	b.SetPos(math32.Vector3{X: x, Z: z})
+	return b
+}
+
+// scatter returns n bodies at random over a square around the origin
+// fitting benchDensity, indexed in a new spatial hash
+func scatter(rng *rand.Rand, n int) (*SpatialHash, []*Body) {
+	side := math32.Sqrt(float32(n) * benchDensity)
+	h := NewSpatialHash(spaceCell)
+	bodies := make([]*Body, n)
+	for i := range bodies {
+		bodies[i] = newBody((rng.Float32()-0.5)*side, (rng.Float32()-0.5)*side)
+		h.Insert(bodies[i])
+	}
+	return h, bodies
+}
+
+// bruteWithin returns the bodies closer than r to p, checking everybody
+func bruteWithin(bodies []*Body, p math32.Vector3, r float32) []*Body {
+	var near []*Body
+	for _, b := range bodies {
+		pos := b.Pos()
+		if pos.DistanceTo(&p) < r {
+			near = append(near, b)
+		}
+	}
+	return near
+}
+
+// sameBodies returns whether a and b hold the same bodies in any order
+func sameBodies(a, b []*Body) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[*Body]int)
+	for _, w := range a {
+		count[w]++
+	}
+	for _, w := range b {
+		if count[w] == 0 {
+			return false
+		}
+		count[w]--
+	}
+	return true
+}
+
+// checkQueries fails unless Within and Nearest of h match a brute
+// force scan of bodies around each query point
+func checkQueries(t *testing.T, h *SpatialHash, bodies []*Body, queries []math32.Vector3) {
+	t.Helper()
+	for _, p := range queries {
+		for _, r := range []float32{0.3, spaceCell, 2.5} {
+			var got []*Body
+			h.Within(p, r, func(w *Body, d float32) bool {
+				got = append(got, w)
+				return true
+			})
+			want := bruteWithin(bodies, p, r)
+			if !sameBodies(got, want) {
+				t.Errorf("Within(%v, %v) found %v bodies, brute force %v", p, r, len(got), len(want))
+			}
+
+			near, ok := h.Nearest(p, r, func(w *Body) bool { return true })
+			if ok != (len(want) > 0) {
+				t.Errorf("Nearest(%v, %v) found %v, brute force found %v bodies", p, r, ok, len(want))
+				continue
+			}
+			if !ok {
+				continue
+			}
+			best := r
+			for _, b := range want {
+				pos := b.Pos()
+				best = min(best, pos.DistanceTo(&p))
+			}
+			pos := near.Pos()
+			if d := pos.DistanceTo(&p); d != best {
+				t.Errorf("Nearest(%v, %v) is %v away, brute force %v", p, r, d, best)
+			}
+		}
+	}
+}
+
+func TestSpatialHashMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	h, bodies := scatter(rng, 200)
+	// bodies on the cell borders and corners, either side of the origin
+	for _, p := range [][2]float32{{0, 0}, {1, 0}, {-1, 0}, {0, -1}, {-1, -1}, {2, -3}, {-0.5, 1}, {-2, -2}} {
+		b := newBody(p[0], p[1])
+		h.Insert(b)
+		bodies = append(bodies, b)
+	}
+	if h.Len() != len(bodies) {
+		t.Fatalf("Len = %v, want %v", h.Len(), len(bodies))
+	}
+	var queries []math32.Vector3
+	for _, p := range [][2]float32{{0, 0}, {-1, -1}, {0.5, -0.5}, {-3.7, 2.2}, {1, 1}, {-10, -10}, {40, 40}} {
+		queries = append(queries, math32.Vector3{X: p[0], Z: p[1]})
+	}
+	for _, b := range bodies[:20] {
+		queries = append(queries, b.Pos())
+	}
+	checkQueries(t, h, bodies, queries)
+
+	// walk half of them across the cells, some onto the borders
+	for i, b := range bodies {
+		if i%2 == 0 {
+			continue
+		}
+		pos := b.Pos()
+		if i%3 == 0 {
+			pos = math32.Vector3{X: math32.Floor(pos.X), Z: math32.Ceil(pos.Z)}
+		} else {
+			pos.X += (rng.Float32() - 0.5) * 4
+			pos.Z += (rng.Float32() - 0.5) * 4
+		}
+		b.SetPos(pos)
+		h.Update(b)
+	}
+	checkQueries(t, h, bodies, queries)
+
+	// and take a third out
+	var kept []*Body
+	for i, b := range bodies {
+		if i%3 == 0 {
+			h.Remove(b)
+		} else {
+			kept = append(kept, b)
+		}
+	}
+	if h.Len() != len(kept) {
+		t.Fatalf("Len after Remove = %v, want %v", h.Len(), len(kept))
+	}
+	checkQueries(t, h, kept, queries)
+}
+
+func TestSpatialHashWithinStops(t *testing.T) {
+	h := NewSpatialHash(spaceCell)
+	for i := 0; i < 5; i++ {
+		h.Insert(newBody(float32(i)*0.1, 0))
+	}
+	calls := 0
+	h.Within(math32.Vector3{}, 1, func(w *Body, d float32) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("Within went on for %v calls after fn returned false", calls)
+	}
+}
+
+// benchWithin measures a neighbour query around every one of n bodies
+func benchWithin(b *testing.B, n int) {
+	h, bodies := scatter(rand.New(rand.NewSource(1)), n)
+	b.ResetTimer()
+	for k := 0; k < b.N; k++ {
+		for _, w := range bodies {
+			h.Within(w.Pos(), sepDist, func(other *Body, d float32) bool {
+				return true
+			})
+		}
+	}
+}
+
+// benchUpdate measures moving every one of n bodies in the hash, a
+// tenth of a cell back and forth
+func benchUpdate(b *testing.B, n int) {
+	h, bodies := scatter(rand.New(rand.NewSource(1)), n)
+	b.ResetTimer()
+	for k := 0; k < b.N; k++ {
+		dx := float32(0.1)
+		if k%2 == 1 {
+			dx = -dx
+		}
+		for _, w := range bodies {
+			w.Agent.Pos.X += dx
+			h.Update(w)
+		}
+	}
+}
+
+func BenchmarkWithin50(b *testing.B)   { benchWithin(b, benchSizes[0]) }
+func BenchmarkWithin200(b *testing.B)  { benchWithin(b, benchSizes[1]) }
+func BenchmarkWithin1000(b *testing.B) { benchWithin(b, benchSizes[2]) }
+func BenchmarkUpdate50(b *testing.B)   { benchUpdate(b, benchSizes[0]) }
+func BenchmarkUpdate200(b *testing.B)  { benchUpdate(b, benchSizes[1]) }
+func BenchmarkUpdate1000(b *testing.B) { benchUpdate(b, benchSizes[2]) }
@@ -0,0 +1,478 @@
+package sim
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/g3n/engine/math32"
+	"github.com/pkg/errors"
+)
+
+// Stage nodes the simulation looks for
+const (
+	SpawnGateNode = "Spawn_Gate" // where characters come in and leave
+	PastureNode   = "Pasture"    // bounds the cows, optional
+	CollisionTag  = "Collision_" // prefix of the obstacles drawn for the nav grid
+)
+
+// DefaultGate is used when the stage has no Spawn_Gate node,
+// it is the opening of the fence.
+var DefaultGate = math32.Vector3{X: -2, Z: 6}
+
+// obstacleNames are the stage nodes characters walk around, by name prefix.
+// A designer can instead add Collision_* nodes to farmstage.gltf,
+// then only those are used and they are not rendered.
+var obstacleNames = []string{"Oak", "Poplar", "Fir-tree", "Cube", "Cylinder",
+	"Fence", "fencee", "house", "Icosphere", "Sphere", "10439_Corn_Field"}
+
+// Stage is what the simulation knows of the stage gltf files: where
+// the gate, the zones, the points of interest and the obstacles are,
+// and the animals walking around.
+type Stage struct {
+	Gate       math32.Vector3 // where characters come in and leave, on the ground
+	Zones      []*Zone        // where characters pick destinations
+	POIs       []*POI         // places characters visit
+	Nav        *NavGrid       // where characters can walk
+	Pasture    math32.Box3    // Pasture node bounds, see HasPasture
+	HasPasture bool
+	Animals    []AnimalStart
+}
+
+// AnimalStart is an animal of the stage files and where it starts
+type AnimalStart struct {
+	Name    string // file base name
+	Profile AnimalProfile
+	Pos     math32.Vector3 // on the ground under its body
+	Yaw     float32        // yaw of its body as authored, radians
+}
+
+// gltfDoc is the part of a gltf file the stage reads
+type gltfDoc struct {
+	Scene  *int `json:"scene"`
+	Scenes []struct {
+		Nodes []int `json:"nodes"`
+	} `json:"scenes"`
+	Nodes []struct {
+		Name        string       `json:"name"`
+		Children    []int        `json:"children"`
+		Mesh        *int         `json:"mesh"`
+		Matrix      *[16]float32 `json:"matrix"`
+		Translation *[3]float32  `json:"translation"`
+		Rotation    *[4]float32  `json:"rotation"`
+		Scale       *[3]float32  `json:"scale"`
+	} `json:"nodes"`
+	Meshes []struct {
+		Primitives []struct {
+			Attributes map[string]int `json:"attributes"`
+		} `json:"primitives"`
+	} `json:"meshes"`
+	Accessors []struct {
+		Min []float32 `json:"min"`
+		Max []float32 `json:"max"`
+	} `json:"accessors"`
+}
+
+// stageNode is a node of a stage file placed in the world
+type stageNode struct {
+	Name     string
+	World    math32.Matrix4
+	Local    math32.Matrix4
+	Box      math32.Box3 // local bounds of its meshes, see HasBox
+	HasBox   bool
+	Children []*stageNode
+}
+
+// LoadStage reads the gltf files of stageDir, with pois.json and
+// zones.json. Points of interest are for the states of conf.
+func LoadStage(stageDir string, conf *BehaviourConfig) (*Stage, error) {
+	files, err := ioutil.ReadDir(stageDir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		if filepath.Ext(f.Name()) == ".gltf" {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	stg := new(Stage)
+	var roots, fixed []*stageNode
+	for _, name := range names {
+		root, err := loadStageFile(filepath.Join(stageDir, name))
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+		// cows and dog walk around on their own
+		if prof, ok := FindAnimalProfile(name); ok {
+			a := AnimalStart{Name: strings.TrimSuffix(name, ".gltf"), Profile: prof}
+			if len(root.Children) > 0 {
+				var scale math32.Vector3
+				var rot math32.Quaternion
+				root.Children[0].Local.Decompose(&a.Pos, &rot, &scale)
+				a.Pos.Y = 0
+				a.Yaw = Yaw(rot)
+			}
+			stg.Animals = append(stg.Animals, a)
+			continue
+		}
+		fixed = append(fixed, root)
+	}
+
+	stg.Gate = DefaultGate
+	if gate := findStageNode(roots, SpawnGateNode); gate != nil {
+		stg.Gate = gate.Pos()
+		stg.Gate.Y = 0
+	} else {
+		log.Debug("No %v in the stage, using %v", SpawnGateNode, DefaultGate)
+	}
+	if err := stg.setupPOIs(stageDir, roots, conf); err != nil {
+		return nil, err
+	}
+	if err := stg.setupZones(stageDir, roots); err != nil {
+		return nil, err
+	}
+	stg.Nav = buildNavGrid(stg.zoneBounds(), fixed)
+	stg.snapPOIs()
+	if n := findStageNode(roots, PastureNode); n != nil {
+		stg.Pasture, stg.HasPasture = n.worldBox()
+	}
+	return stg, nil
+}
+
+// loadStageFile returns the default scene of the gltf file, its
+// nodes placed in the world
+func loadStageFile(file string) (*stageNode, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var doc gltfDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "decoding "+file)
+	}
+	scene := 0
+	if doc.Scene != nil {
+		scene = *doc.Scene
+	}
+	if scene >= len(doc.Scenes) {
+		return nil, errors.Errorf("%v has no scene %v", file, scene)
+	}
+
+	var load func(i int, parent *math32.Matrix4) *stageNode
+	load = func(i int, parent *math32.Matrix4) *stageNode {
+		nd := doc.Nodes[i]
+		n := &stageNode{Name: nd.Name}
+		if nd.Matrix != nil {
+			n.Local = math32.Matrix4(*nd.Matrix)
+		} else {
+			pos := math32.Vector3{}
+			rot := math32.Quaternion{W: 1}
+			scale := math32.Vector3{X: 1, Y: 1, Z: 1}
+			if t := nd.Translation; t != nil {
+				pos.Set(t[0], t[1], t[2])
+			}
+			if r := nd.Rotation; r != nil {
+				rot.Set(r[0], r[1], r[2], r[3])
+			}
+			if s := nd.Scale; s != nil {
+				scale.Set(s[0], s[1], s[2])
+			}
+			n.Local.Compose(&pos, &rot, &scale)
+		}
+		n.World.MultiplyMatrices(parent, &n.Local)
+		if nd.Mesh != nil && *nd.Mesh < len(doc.Meshes) {
+			for _, prim := range doc.Meshes[*nd.Mesh].Primitives {
+				acc, ok := prim.Attributes["POSITION"]
+				if !ok || acc >= len(doc.Accessors) {
+					continue
+				}
+				lo, hi := doc.Accessors[acc].Min, doc.Accessors[acc].Max
+				if len(lo) < 3 || len(hi) < 3 {
+					continue
+				}
+				b := math32.Box3{
+					Min: math32.Vector3{X: lo[0], Y: lo[1], Z: lo[2]},
+					Max: math32.Vector3{X: hi[0], Y: hi[1], Z: hi[2]},
+				}
+				if n.HasBox {
+					n.Box.Union(&b)
+				} else {
+					n.Box, n.HasBox = b, true
+				}
+			}
+		}
+		for _, child := range nd.Children {
+			n.Children = append(n.Children, load(child, &n.World))
+		}
+		return n
+	}
+
+	root := &stageNode{Name: filepath.Base(file)}
+	root.Local.Identity()
+	root.World.Identity()
+	for _, i := range doc.Scenes[scene].Nodes {
+		root.Children = append(root.Children, load(i, &root.World))
+	}
+	return root, nil
+}
+
+// Pos returns the world position of the node
+func (n *stageNode) Pos() math32.Vector3 {
+	var pos math32.Vector3
+	pos.SetFromMatrixPosition(&n.World)
+	return pos
+}
+
+// worldBox returns the world space bounding box of the meshes under n
+func (n *stageNode) worldBox() (math32.Box3, bool) {
+	var box math32.Box3
+	found := false
+	if n.HasBox {
+		box = n.Box
+		box.ApplyMatrix4(&n.World)
+		found = true
+	}
+	for _, child := range n.Children {
+		if b, ok := child.worldBox(); ok {
+			if found {
+				box.Union(&b)
+			} else {
+				box, found = b, true
+			}
+		}
+	}
+	return box, found
+}
+
+// corners returns the XZ corners of the bounding boxes of the meshes under n
+func (n *stageNode) corners() []math32.Vector3 {
+	var points []math32.Vector3
+	if n.HasBox {
+		for i := 0; i < 8; i++ {
+			p := n.Box.Min
+			if i&1 != 0 {
+				p.X = n.Box.Max.X
+			}
+			if i&2 != 0 {
+				p.Y = n.Box.Max.Y
+			}
+			if i&4 != 0 {
+				p.Z = n.Box.Max.Z
+			}
+			p.ApplyMatrix4(&n.World)
+			points = append(points, math32.Vector3{X: p.X, Z: p.Z})
+		}
+	}
+	for _, child := range n.Children {
+		points = append(points, child.corners()...)
+	}
+	return points
+}
+
+// findStageNode returns the first node named name under roots
+func findStageNode(roots []*stageNode, name string) *stageNode {
+	for _, n := range roots {
+		if n.Name == name {
+			return n
+		}
+		if found := findStageNode(n.Children, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// collectStageNodes appends the topmost nodes under roots matching match
+func collectStageNodes(roots []*stageNode, match func(*stageNode) bool, found *[]*stageNode) {
+	for _, n := range roots {
+		for _, child := range n.Children {
+			if match(child) {
+				*found = append(*found, child)
+				continue
+			}
+			collectStageNodes([]*stageNode{child}, match, found)
+		}
+	}
+}
+
+// setupPOIs reads the POI_* nodes of the stage, and their
+// activity and capacity from POIS_FILENAME.
+func (stg *Stage) setupPOIs(stageDir string, roots []*stageNode, conf *BehaviourConfig) error {
+	confs := make(map[string]POIConfig)
+	data, err := ioutil.ReadFile(filepath.Join(stageDir, POIS_FILENAME))
+	if err == nil {
+		if err := json.Unmarshal(data, &confs); err != nil {
+			return errors.Wrap(err, "decoding "+POIS_FILENAME)
+		}
+	}
+
+	var nodes []*stageNode
+	collectStageNodes(roots, func(n *stageNode) bool {
+		return strings.HasPrefix(n.Name, poiTag)
+	}, &nodes)
+
+	for _, n := range nodes {
+		pc := confs[n.Name]
+		if pc.Activity == "" {
+			activity := strings.TrimPrefix(n.Name, poiTag)
+			if i := strings.IndexAny(activity, "_."); i >= 0 {
+				activity = activity[:i]
+			}
+			pc.Activity = BehaviourState(strings.ToLower(activity))
+		}
+		if pc.Capacity <= 0 {
+			pc.Capacity = 1
+		}
+		if _, ok := conf.States[pc.Activity]; !ok {
+			log.Debug("%v: no behaviour state %v", n.Name, pc.Activity)
+			continue
+		}
+		pos := n.Pos()
+		pos.Y = 0
+		poi := newPOI(n.Name, pc.Activity, pos, pc.Capacity)
+		stg.POIs = append(stg.POIs, poi)
+		log.Debug("POI %v %v for %v at %v", n.Name, poi.Activity, pc.Capacity, poi.Pos)
+	}
+	return nil
+}
+
+// snapPOIs puts the places of the points of interest on walkable
+// ground, the points without any are dropped
+func (stg *Stage) snapPOIs() {
+	pois := stg.POIs[:0]
+	for _, poi := range stg.POIs {
+		if poi.snapPlaces(stg.Nav) {
+			pois = append(pois, poi)
+		}
+	}
+	stg.POIs = pois
+}
+
+// setupZones reads the Zone_* nodes of the stage, and their weights
+// from ZONES_FILENAME. Without any, the Ground node is the only zone.
+func (stg *Stage) setupZones(stageDir string, roots []*stageNode) error {
+	weights := make(map[string]float32)
+	data, err := ioutil.ReadFile(filepath.Join(stageDir, ZONES_FILENAME))
+	if err == nil {
+		if err := json.Unmarshal(data, &weights); err != nil {
+			return errors.Wrap(err, "decoding "+ZONES_FILENAME)
+		}
+	}
+
+	var nodes []*stageNode
+	collectStageNodes(roots, func(n *stageNode) bool {
+		return strings.HasPrefix(n.Name, zoneTag)
+	}, &nodes)
+
+	for _, n := range nodes {
+		weight, ok := weights[n.Name]
+		if !ok {
+			weight = 1
+		}
+		z := NewZone(n.Name, zonePoints(n), weight)
+		if z.area <= 0 {
+			log.Debug("Zone %v is empty", n.Name)
+			continue
+		}
+		stg.Zones = append(stg.Zones, z)
+	}
+	if len(stg.Zones) == 0 {
+		yard := stg.yardBounds(roots)
+		stg.Zones = append(stg.Zones, NewZone("Yard", []math32.Vector3{
+			yard.Min, {X: yard.Max.X, Z: yard.Min.Z},
+			yard.Max, {X: yard.Min.X, Z: yard.Max.Z},
+		}, 1))
+	}
+	for _, z := range stg.Zones {
+		log.Debug("Zone %v %v area %.1f weight %v", z.Name, z.Box, z.area, z.Weight)
+	}
+	return nil
+}
+
+// yardBounds returns the Ground node bounds on the ground, or without
+// one the area around the gate and the points of interest
+func (stg *Stage) yardBounds(roots []*stageNode) math32.Box3 {
+	var yard math32.Box3
+	found := false
+	if ground := findStageNode(roots, groundNode); ground != nil {
+		yard, found = ground.worldBox()
+	}
+	if !found {
+		yard = math32.Box3{Min: stg.Gate, Max: stg.Gate}
+		for _, poi := range stg.POIs {
+			yard.ExpandByPoint(&poi.Pos)
+		}
+		yard.ExpandByScalar(navMargin)
+	}
+	yard.Min.Y, yard.Max.Y = 0, 0
+	return yard
+}
+
+// zonePoints returns the XZ points outlining a zone node: the corners
+// of its meshes, or for an empty the square its scale spans.
+func zonePoints(n *stageNode) []math32.Vector3 {
+	if points := n.corners(); len(points) > 0 {
+		return points
+	}
+	var pos, scale math32.Vector3
+	var rot math32.Quaternion
+	n.World.Decompose(&pos, &rot, &scale)
+	return []math32.Vector3{
+		{X: pos.X - scale.X, Z: pos.Z - scale.Z}, {X: pos.X + scale.X, Z: pos.Z - scale.Z},
+		{X: pos.X + scale.X, Z: pos.Z + scale.Z}, {X: pos.X - scale.X, Z: pos.Z + scale.Z},
+	}
+}
+
+// buildNavGrid marks the obstacles of the stage files in a new grid
+// covering bounds. Animals move, bodies keep away from them by steering.
+func buildNavGrid(bounds math32.Box3, roots []*stageNode) *NavGrid {
+	g := NewNavGrid(bounds, navCell)
+
+	var layer []*stageNode
+	collectStageNodes(roots, func(n *stageNode) bool {
+		return strings.HasPrefix(n.Name, CollisionTag)
+	}, &layer)
+	if len(layer) > 0 {
+		log.Debug("Using %v collision nodes", len(layer))
+		for _, n := range layer {
+			if box, ok := n.worldBox(); ok {
+				g.Block(box, navClearance)
+			}
+		}
+		return g
+	}
+
+	var obstacles []*stageNode
+	collectStageNodes(roots, func(n *stageNode) bool {
+		return hasPrefix(n.Name, obstacleNames)
+	}, &obstacles)
+	for _, n := range obstacles {
+		if box, ok := n.worldBox(); ok && box.Min.Y < navHeadroom {
+			g.Block(box, navClearance)
+		}
+	}
+	log.Debug("Nav grid %vx%v with %v obstacles", g.W, g.H, len(obstacles))
+	return g
+}
+
+// hasPrefix returns whether name starts with one of prefixes
+func hasPrefix(name string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMarker returns whether the stage node named name only marks a
+// place for the simulation, the farm does not render those.
+func IsMarker(name string) bool {
+	return hasPrefix(name, []string{zoneTag, poiTag, CollisionTag, PastureNode})
+}
@@ -0,0 +1,174 @@
+package sim
+
+import (
+	"github.com/g3n/engine/math32"
+)
+
+// Agent is the steering state of something walking on the farm.
+// It only works on the XZ plane and knows nothing of nodes or the
+// renderer, the caller copies Pos from and to its node.
+type Agent struct {
+	Pos      math32.Vector3
+	Vel      math32.Vector3
+	MaxSpeed float32 // top speed, units per second
+	MaxForce float32 // most the velocity may change in a second
+
+	wanderAngle float32
+}
+
+// Steering weights and distances
+const (
+	slowRadius  = 0.6 // Arrive slows down within this distance
+	sepDist     = 0.6 // neighbours closer than this push away
+	lookAhead   = 0.6 // how far ahead AvoidObstacles probes
+	wanderDist  = 1.0 // wander circle distance ahead
+	wanderR     = 0.4 // wander circle radius
+	wanderTurn  = 18  // most the wander angle changes in a second
+	sepWeight   = 1.0
+	avoidWeight = 2.0
+	wanderW     = 0.3
+)
+
+// NewAgent returns an Agent at rest at pos, its velocity can change
+// by accel times maxSpeed in a second.
+func NewAgent(pos math32.Vector3, maxSpeed, accel float32) Agent {
+	return Agent{Pos: pos, MaxSpeed: maxSpeed, MaxForce: maxSpeed * accel}
+}
+
+// flat returns v on the XZ plane
+func flat(v math32.Vector3) math32.Vector3 {
+	v.Y = 0
+	return v
+}
+
+// limit scales v down to a length of max at most
+func limit(v math32.Vector3, max float32) math32.Vector3 {
+	if l := v.Length(); l > max && l > 0 {
+		v.MultiplyScalar(max / l)
+	}
+	return v
+}
+
+// Seek returns the force turning the agent to target at full speed
+func (a *Agent) Seek(target math32.Vector3) math32.Vector3 {
+	desired := flat(target)
+	desired.Sub(&a.Pos)
+	desired.Y = 0
+	if desired.Length() == 0 {
+		return math32.Vector3{}
+	}
+	desired.Normalize().MultiplyScalar(a.MaxSpeed)
+	return *desired.Sub(&a.Vel)
+}
+
+// Arrive is Seek slowing down to stop on target
+func (a *Agent) Arrive(target math32.Vector3) math32.Vector3 {
+	desired := flat(target)
+	desired.Sub(&a.Pos)
+	desired.Y = 0
+	dist := desired.Length()
+	if dist == 0 {
+		return *desired.Sub(&a.Vel)
+	}
+	speed := a.MaxSpeed
+	if dist < slowRadius {
+		speed *= dist / slowRadius
+	}
+	desired.Normalize().MultiplyScalar(speed)
+	return *desired.Sub(&a.Vel)
+}
+
+// Wander returns a force that turns the agent a bit at random,
+// jitter in [-1,1] is where the wander target moves in dt seconds.
+func (a *Agent) Wander(jitter, dt float32) math32.Vector3 {
+	a.wanderAngle += jitter * wanderTurn * dt
+	heading := a.Vel
+	if heading.Length() == 0 {
+		return math32.Vector3{}
+	}
+	heading.Normalize()
+	target := heading
+	target.MultiplyScalar(wanderDist)
+	target.X += math32.Cos(a.wanderAngle) * wanderR
+	target.Z += math32.Sin(a.wanderAngle) * wanderR
+	target.Add(&a.Pos)
+	return a.Seek(target)
+}
+
+// Separate returns the force pushing the agent away from the
+// neighbours closer than sepDist, the nearer the stronger.
+func (a *Agent) Separate(neighbours []math32.Vector3) math32.Vector3 {
+	var push math32.Vector3
+	count := 0
+	for _, n := range neighbours {
+		away := a.Pos
+		away.Sub(&n)
+		away.Y = 0
+		d := away.Length()
+		if d >= sepDist {
+			continue
+		}
+		if d == 0 {
+			// right on top of each other, any way out will do
+			away = math32.Vector3{X: 1}
+			d = 0.01
+		}
+		away.Normalize().MultiplyScalar((sepDist - d) / sepDist)
+		push.Add(&away)
+		count++
+	}
+	if count == 0 {
+		return push
+	}
+	push.Normalize().MultiplyScalar(a.MaxSpeed)
+	return *push.Sub(&a.Vel)
+}
+
+// AvoidObstacles probes ahead of the agent and returns the force
+// turning it to the free side when the way is blocked.
+func (a *Agent) AvoidObstacles(walkable func(p math32.Vector3) bool) math32.Vector3 {
+	heading := a.Vel
+	if heading.Length() == 0 {
+		return math32.Vector3{}
+	}
+	heading.Normalize()
+	ahead := heading
+	ahead.MultiplyScalar(lookAhead).Add(&a.Pos)
+	if walkable(ahead) {
+		return math32.Vector3{}
+	}
+	// feelers 45 degrees left and right
+	side := math32.Vector3{X: -heading.Z, Z: heading.X}
+	for _, s := range []float32{1, -1} {
+		feel := side
+		feel.MultiplyScalar(s).Add(&heading).Normalize().MultiplyScalar(lookAhead).Add(&a.Pos)
+		if walkable(feel) {
+			return a.Seek(feel)
+		}
+	}
+	// boxed in, back off
+	back := heading
+	back.MultiplyScalar(-a.MaxSpeed)
+	return *back.Sub(&a.Vel)
+}
+
+// Step applies the force for dt seconds and moves the agent
+func (a *Agent) Step(force math32.Vector3, dt float32) {
+	force = limit(flat(force), a.MaxForce)
+	force.MultiplyScalar(dt)
+	a.Vel.Add(&force)
+	a.Vel = limit(a.Vel, a.MaxSpeed)
+	move := a.Vel
+	move.MultiplyScalar(dt)
+	a.Pos.Add(&move)
+}
+
+// Stop brings the agent to rest at once
+func (a *Agent) Stop() {
+	a.Vel = math32.Vector3{}
+}
+
+// Speed returns how fast the agent goes
+func (a *Agent) Speed() float32 {
+	return a.Vel.Length()
+}
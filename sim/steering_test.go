@@ -0,0 +1,100 @@
+package sim
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+// nearVec returns whether a and b are within tol on every axis
+func nearVec(a, b math32.Vector3, tol float32) bool {
+	return almostEq(a.X, b.X, tol) && almostEq(a.Y, b.Y, tol) && almostEq(a.Z, b.Z, tol)
+}
+
+func TestSeek(t *testing.T) {
+	a := NewAgent(math32.Vector3{}, 1, 1)
+	// full speed to the target, on the ground
+	if got := a.Seek(math32.Vector3{X: 3, Y: 5}); !nearVec(got, math32.Vector3{X: 1}, 1e-5) {
+		t.Errorf("Seek at rest = %v, want {1 0 0}", got)
+	}
+	// the force takes off the current velocity
+	a.Vel = math32.Vector3{Z: 1}
+	if got := a.Seek(math32.Vector3{X: 3}); !nearVec(got, math32.Vector3{X: 1, Z: -1}, 1e-5) {
+		t.Errorf("Seek moving = %v, want {1 0 -1}", got)
+	}
+	if got := a.Seek(a.Pos); got != (math32.Vector3{}) {
+		t.Errorf("Seek on target = %v, want none", got)
+	}
+}
+
+func TestArrive(t *testing.T) {
+	a := NewAgent(math32.Vector3{}, 1, 1)
+	if got := a.Arrive(math32.Vector3{X: 3}); !nearVec(got, math32.Vector3{X: 1}, 1e-5) {
+		t.Errorf("Arrive far = %v, want full speed", got)
+	}
+	// slows down within slowRadius
+	want := math32.Vector3{X: 0.5}
+	if got := a.Arrive(math32.Vector3{X: slowRadius / 2}); !nearVec(got, want, 1e-5) {
+		t.Errorf("Arrive near = %v, want %v", got, want)
+	}
+	a.Vel = math32.Vector3{X: 0.4}
+	if got := a.Arrive(a.Pos); !nearVec(got, math32.Vector3{X: -0.4}, 1e-5) {
+		t.Errorf("Arrive on target = %v, want to stop", got)
+	}
+
+	// and stops on the target
+	a = NewAgent(math32.Vector3{}, 1, 6)
+	target := math32.Vector3{X: 2, Z: 1}
+	for i := 0; i < 600; i++ {
+		a.Step(a.Arrive(target), 1.0/60)
+	}
+	if !nearVec(a.Pos, target, 0.05) || a.Speed() > 0.05 {
+		t.Errorf("Arrive ended at %v going %v, want at rest on %v", a.Pos, a.Speed(), target)
+	}
+}
+
+func TestSeparate(t *testing.T) {
+	a := NewAgent(math32.Vector3{}, 1, 1)
+	if got := a.Separate([]math32.Vector3{{X: sepDist + 0.1}, {Z: -sepDist}}); got != (math32.Vector3{}) {
+		t.Errorf("Separate from far neighbours = %v, want none", got)
+	}
+	if got := a.Separate([]math32.Vector3{{X: 0.3}}); !nearVec(got, math32.Vector3{X: -1}, 1e-5) {
+		t.Errorf("Separate = %v, want away from the neighbour", got)
+	}
+	// neighbours on both sides along X push along Z
+	got := a.Separate([]math32.Vector3{{X: 0.3, Z: -0.1}, {X: -0.3, Z: -0.1}})
+	if !nearVec(got, math32.Vector3{Z: 1}, 1e-5) {
+		t.Errorf("Separate between two = %v, want {0 0 1}", got)
+	}
+	if got := a.Separate([]math32.Vector3{a.Pos}); got.Length() == 0 {
+		t.Error("Separate on top of a neighbour does not push")
+	}
+}
+
+func TestAvoidObstacles(t *testing.T) {
+	a := NewAgent(math32.Vector3{}, 1, 1)
+	a.Vel = math32.Vector3{X: 1}
+	free := func(p math32.Vector3) bool { return true }
+	if got := a.AvoidObstacles(free); got != (math32.Vector3{}) {
+		t.Errorf("AvoidObstacles on a free way = %v, want none", got)
+	}
+	// a post right ahead, the agent turns to the left feeler
+	post := func(p math32.Vector3) bool { return p.X < 0.3 || math32.Abs(p.Z) > 0.2 }
+	if got := a.AvoidObstacles(post); got.Z <= 0 {
+		t.Errorf("AvoidObstacles around a post = %v, want a turn to +Z", got)
+	}
+	// the left side is blocked too, it turns right
+	left := func(p math32.Vector3) bool { return p.X < 0.3 || p.Z < -0.2 }
+	if got := a.AvoidObstacles(left); got.Z >= 0 {
+		t.Errorf("AvoidObstacles with the left blocked = %v, want a turn to -Z", got)
+	}
+	// boxed in, it backs off
+	wall := func(p math32.Vector3) bool { return p.X < 0.3 }
+	if got := a.AvoidObstacles(wall); got.X >= 0 {
+		t.Errorf("AvoidObstacles at a wall = %v, want to back off", got)
+	}
+	a.Stop()
+	if got := a.AvoidObstacles(wall); got != (math32.Vector3{}) {
+		t.Errorf("AvoidObstacles at rest = %v, want none", got)
+	}
+}
@@ -0,0 +1,73 @@
+package sim
+
+import (
+	"math/rand"
+
+	"github.com/g3n/engine/math32"
+)
+
+// TraitLimits bounds the traits of an archetype, zero ranges use the defaults
+type TraitLimits struct {
+	Height   Range `json:"height"`   // uniform scale
+	Build    Range `json:"build"`    // extra scale across, above 1 is stockier
+	Pace     Range `json:"pace"`     // walk speed multiplier
+	IdleFreq Range `json:"idleFreq"` // chance to idle at a destination
+}
+
+var defaultTraitLimits = TraitLimits{
+	Height:   Range{0.9, 1.1},
+	Build:    Range{0.9, 1.15},
+	Pace:     Range{0.8, 1.25},
+	IdleFreq: Range{0.1, 0.4},
+}
+
+// clothTints are the farm clothing colors, white keeps the original
+var clothTints = []math32.Color{
+	{R: 1, G: 1, B: 1},
+	{R: 0.55, G: 0.65, B: 0.9}, // denim
+	{R: 0.9, G: 0.45, B: 0.4},  // plaid red
+	{R: 0.55, G: 0.8, B: 0.5},  // field green
+	{R: 0.8, G: 0.65, B: 0.45}, // straw brown
+	{R: 0.95, G: 0.85, B: 0.45},
+}
+
+// Traits make characters of the same archetype look and move differently
+type Traits struct {
+	Height   float32
+	Build    float32
+	Pace     float32
+	IdleFreq float32
+	Tint     math32.Color // applied on the clothes where the archetype has a cloth mask
+}
+
+// Generated returns whether the traits were generated
+func (t Traits) Generated() bool {
+	return t.Height != 0
+}
+
+// RandomTraits returns traits within the archetype limits. They are derived
+// from the captured face hash so the same face gets the same farmer.
+func (arch Archetype) RandomTraits(faceHash uint64) Traits {
+	lim := arch.Traits
+	if lim.Height == (Range{}) {
+		lim.Height = defaultTraitLimits.Height
+	}
+	if lim.Build == (Range{}) {
+		lim.Build = defaultTraitLimits.Build
+	}
+	if lim.Pace == (Range{}) {
+		lim.Pace = defaultTraitLimits.Pace
+	}
+	if lim.IdleFreq == (Range{}) {
+		lim.IdleFreq = defaultTraitLimits.IdleFreq
+	}
+
+	rng := rand.New(rand.NewSource(int64(faceHash)))
+	return Traits{
+		Height:   lim.Height.At(rng.Float32()),
+		Build:    lim.Build.At(rng.Float32()),
+		Pace:     lim.Pace.At(rng.Float32()),
+		IdleFreq: lim.IdleFreq.At(rng.Float32()),
+		Tint:     clothTints[rng.Intn(len(clothTints))],
+	}
+}
@@ -0,0 +1,80 @@
+package sim
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/g3n/engine/math32"
+)
+
+// World is the farm simulation: the characters and animals on the stage.
+// Only Step moves it, the same seed and steps replay the same run.
+type World struct {
+	Clock      time.Time  // simulation time
+	Opened     time.Time  // Clock when the farm opened, its first day starts there
+	Rng        *rand.Rand // all the randomness of the simulation
+	Behaviour  *BehaviourConfig
+	Archetypes []Archetype
+	Stage      *Stage
+	Camera     math32.Vector3 // where the visitors watch from, characters wave there
+	Space      *SpatialHash   // where the characters and animals are, for neighbour queries
+	Animals    []*Animal
+	Groups     map[GroupID]*Group
+
+	// OnRemove is called when a character leaves the world
+	OnRemove func(c *Char)
+
+	chars  map[CharID]*Char
+	order  []CharID // ids in creation order
+	nextID CharID
+}
+
+// NewWorld returns a world on the stage starting at clock, drawing from rng.
+// The animals of the stage are at their start.
+func NewWorld(stage *Stage, archs []Archetype, behaviour *BehaviourConfig, clock time.Time, rng *rand.Rand) *World {
+	w := &World{
+		Clock:      clock,
+		Opened:     clock,
+		Rng:        rng,
+		Behaviour:  behaviour,
+		Archetypes: archs,
+		Stage:      stage,
+		Space:      NewSpatialHash(spaceCell),
+		Groups:     make(map[GroupID]*Group),
+		chars:      make(map[CharID]*Char),
+	}
+	for _, start := range stage.Animals {
+		w.addAnimal(start)
+	}
+	w.setupPastures()
+	return w
+}
+
+// Step advances the world by delta seconds
+func (w *World) Step(delta float32) {
+	w.Clock = w.Clock.Add(time.Duration(delta * float32(time.Second)))
+	for _, c := range w.Chars() {
+		w.MoveChar(c, delta)
+	}
+	for _, a := range w.Animals {
+		w.updateAnimal(a, delta)
+	}
+	for _, c := range w.Chars() {
+		w.updateGate(c, delta)
+	}
+}
+
+// Chars returns the characters in creation order
+func (w *World) Chars() []*Char {
+	all := make([]*Char, 0, len(w.order))
+	for _, id := range w.order {
+		all = append(all, w.chars[id])
+	}
+	return all
+}
+
+// Char returns the character with the given id
+func (w *World) Char(id CharID) (*Char, bool) {
+	c, ok := w.chars[id]
+	return c, ok
+}
@@ -0,0 +1,142 @@
+package sim
+
+import (
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/g3n/engine/math32"
+)
+
+const assetsDir = "../assets"
+
+// newTestWorld returns a world on the shipped stage and archetypes
+func newTestWorld(t *testing.T, seed int64) *World {
+	charDir := filepath.Join(assetsDir, "character")
+	conf, err := LoadBehaviour(charDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archs, err := LoadArchetypes(charDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage, err := LoadStage(filepath.Join(assetsDir, "stage"), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+	return NewWorld(stage, archs, conf, start, rand.New(rand.NewSource(seed)))
+}
+
+func TestRunIsDeterministic(t *testing.T) {
+	const ticks, step, chars = 1800, 1.0 / 30, 6
+	first := newTestWorld(t, 42).Run(ticks, step, chars)
+	second := newTestWorld(t, 42).Run(ticks, step, chars)
+
+	if len(first.Samples) != len(second.Samples) {
+		t.Fatalf("runs have %v and %v samples", len(first.Samples), len(second.Samples))
+	}
+	for i := range first.Samples {
+		if !reflect.DeepEqual(first.Samples[i], second.Samples[i]) {
+			t.Fatalf("runs differ at sample %v: %+v and %+v", i, first.Samples[i], second.Samples[i])
+		}
+	}
+
+	// the characters walk around, the run is not trivially the same
+	moved := make(map[CharID]bool)
+	for _, s := range first.Samples {
+		if s.Kind == "char" && s.Speed > 0 {
+			moved[s.ID] = true
+		}
+	}
+	if len(moved) != chars {
+		t.Errorf("%v of %v characters walked", len(moved), chars)
+	}
+}
+
+func TestRunDependsOnSeed(t *testing.T) {
+	first := newTestWorld(t, 1).Run(600, 1.0/30, 3)
+	second := newTestWorld(t, 2).Run(600, 1.0/30, 3)
+	if reflect.DeepEqual(first.Samples, second.Samples) {
+		t.Error("runs with different seeds are the same")
+	}
+}
+
+func TestLoadStage(t *testing.T) {
+	w := newTestWorld(t, 1)
+	stg := w.Stage
+	if len(stg.Zones) == 0 || len(stg.POIs) == 0 || stg.Nav == nil {
+		t.Fatalf("stage has %v zones, %v POIs, nav %v", len(stg.Zones), len(stg.POIs), stg.Nav)
+	}
+	if len(w.Animals) == 0 {
+		t.Fatal("stage has no animals")
+	}
+	if !stg.Nav.Walkable(stg.Gate) {
+		t.Errorf("gate %v is not walkable", stg.Gate)
+	}
+	for _, a := range w.Animals {
+		if !a.Profile.UseNav && !a.Region.ContainsPoint(&a.Agent.Pos) {
+			t.Errorf("%v starts at %v out of its pasture %v", a.Name, a.Agent.Pos, a.Region)
+		}
+	}
+}
+
+func TestPOIPlacesReachable(t *testing.T) {
+	stg := newTestWorld(t, 1).Stage
+	for _, poi := range stg.POIs {
+		if len(poi.places) == 0 {
+			t.Errorf("%v has no place", poi.Name)
+		}
+		for i := range poi.places {
+			place := poi.slotPos(i)
+			if !stg.Nav.Walkable(place) {
+				t.Errorf("%v place %v at %v is not walkable", poi.Name, i, place)
+				continue
+			}
+			path, ok := stg.Nav.FindPath(stg.Gate, place)
+			if !ok {
+				t.Errorf("%v place %v at %v cannot be reached from the gate", poi.Name, i, place)
+				continue
+			}
+			if end := path[len(path)-1]; end != place {
+				t.Errorf("path to %v place %v ends at %v, want %v", poi.Name, i, end, place)
+			}
+		}
+	}
+}
+
+func TestPOIPlacesSnapped(t *testing.T) {
+	nav := NewNavGrid(math32.Box3{Max: math32.Vector3{X: 10, Y: 1, Z: 10}}, 1)
+	nav.Block(math32.Box3{Min: math32.Vector3{X: 4.2, Z: 4.2}, Max: math32.Vector3{X: 5.8, Z: 5.8}}, 0)
+	poi := newPOI("POI_Rest_Test", StateRest, math32.Vector3{X: 5, Z: 5}, 2)
+	if !poi.snapPlaces(nav) || len(poi.slots) != 2 {
+		t.Fatalf("snapPlaces dropped places, %v left", len(poi.slots))
+	}
+	for i, p := range poi.places {
+		if !nav.Walkable(p) {
+			t.Errorf("place %v at %v is in the obstacle", i, p)
+		}
+	}
+
+	// nowhere to stand
+	nav.Block(math32.Box3{Max: math32.Vector3{X: 10, Z: 10}}, 0)
+	if poi.snapPlaces(nav) {
+		t.Errorf("snapPlaces kept %v places on a blocked grid", len(poi.places))
+	}
+}
+
+func TestRandCoordWalkable(t *testing.T) {
+	nav := newTestGrid()
+	// only the far row of the zone is open
+	nav.Block(math32.Box3{Max: math32.Vector3{X: 10, Z: 8.5}}, 0)
+	zone := NewZone("Zone_Test", []math32.Vector3{{}, {X: 10}, {X: 10, Z: 10}, {Z: 10}}, 1)
+	w := &World{Stage: &Stage{Zones: []*Zone{zone}, Nav: nav}, Rng: rand.New(rand.NewSource(1))}
+	for i := 0; i < 200; i++ {
+		if p := w.randCoord(); !nav.Walkable(p) {
+			t.Fatalf("randCoord returned %v in the obstacle", p)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package sim
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/g3n/engine/math32"
+)
+
+// ZONES_FILENAME is the optional file in stageDir weighting the zones
+const ZONES_FILENAME string = "zones.json"
+
+const (
+	zoneTag    = "Zone_"
+	groundNode = "Ground"
+	navMargin  = 2.0 // the nav grid goes that far around what it covers
+	zoneTries  = 20  // random points tried for a walkable one
+)
+
+// Zone is a named area of the stage characters pick destinations in,
+// on the XZ plane.
+type Zone struct {
+	Name   string
+	Poly   []math32.Vector3 // convex outline on the ground
+	Weight float32          // chance per unit area to be picked, 1 by default
+	Box    math32.Box3      // bounds of Poly
+	area   float32
+}
+
+// NewZone returns the zone outlined by the convex hull of points
+func NewZone(name string, points []math32.Vector3, weight float32) *Zone {
+	z := &Zone{Name: name, Poly: convexHull(points), Weight: weight}
+	if len(z.Poly) > 0 {
+		z.Box = math32.Box3{Min: z.Poly[0], Max: z.Poly[0]}
+	}
+	for i := range z.Poly {
+		z.Box.ExpandByPoint(&z.Poly[i])
+	}
+	z.area = polyArea(z.Poly)
+	return z
+}
+
+// Contains returns whether p is inside the zone, seen from above
+func (z *Zone) Contains(p math32.Vector3) bool {
+	if len(z.Poly) < 3 {
+		return false
+	}
+	// on the left of every edge of the counter clockwise outline
+	for i := range z.Poly {
+		a, b := z.Poly[i], z.Poly[(i+1)%len(z.Poly)]
+		if cross(a, b, p) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// randPoint returns a random point inside the zone, on the ground.
+// When every try misses, the centroid of the outline is used.
+func (z *Zone) randPoint(rng *rand.Rand) math32.Vector3 {
+	var p math32.Vector3
+	for try := 0; try < zoneTries; try++ {
+		p.X = z.Box.Min.X + rng.Float32()*(z.Box.Max.X-z.Box.Min.X)
+		p.Z = z.Box.Min.Z + rng.Float32()*(z.Box.Max.Z-z.Box.Min.Z)
+		if z.Contains(p) {
+			return p
+		}
+	}
+	return z.centroid()
+}
+
+// centroid returns the average of the outline vertices, inside the
+// convex zone
+func (z *Zone) centroid() math32.Vector3 {
+	var c math32.Vector3
+	for i := range z.Poly {
+		c.Add(&z.Poly[i])
+	}
+	if len(z.Poly) > 0 {
+		c.DivideScalar(float32(len(z.Poly)))
+	}
+	return c
+}
+
+// pickZone returns a zone by weight times area
+func (w *World) pickZone() *Zone {
+	zones := w.Stage.Zones
+	var total float32
+	for _, z := range zones {
+		total += z.Weight * z.area
+	}
+	u := w.Rng.Float32() * total
+	for _, z := range zones {
+		if u < z.Weight*z.area {
+			return z
+		}
+		u -= z.Weight * z.area
+	}
+	return zones[len(zones)-1]
+}
+
+// randCoord returns a random place in one of the zones, out of the
+// obstacles. After zoneTries misses the open cell nearest the last
+// point is used.
+func (w *World) randCoord() math32.Vector3 {
+	nav := w.Stage.Nav
+	for try := 0; ; try++ {
+		p := w.pickZone().randPoint(w.Rng)
+		if nav == nil || nav.Walkable(p) {
+			return p
+		}
+		if try == zoneTries {
+			p, _ = nav.snap(p)
+			return p
+		}
+	}
+}
+
+// zoneBounds returns the box around the zones, the gate and the
+// points of interest, the nav grid covers it.
+func (stg *Stage) zoneBounds() math32.Box3 {
+	bounds := math32.Box3{Min: stg.Gate, Max: stg.Gate}
+	for _, z := range stg.Zones {
+		bounds.Union(&z.Box)
+	}
+	for _, poi := range stg.POIs {
+		bounds.ExpandByPoint(&poi.Pos)
+	}
+	bounds.ExpandByScalar(navMargin)
+	bounds.Min.Y, bounds.Max.Y = 0, 0
+	return bounds
+}
+
+// convexHull returns the counter clockwise convex hull of points
+func convexHull(points []math32.Vector3) []math32.Vector3 {
+	ps := append([]math32.Vector3(nil), points...)
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].X < ps[j].X || ps[i].X == ps[j].X && ps[i].Z < ps[j].Z
+	})
+	if len(ps) < 3 {
+		return ps
+	}
+	// Andrew's monotone chain, lower then upper half
+	hull := make([]math32.Vector3, 0, 2*len(ps))
+	for _, p := range ps {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(ps) - 2; i >= 0; i-- {
+		p := ps[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return hull[:len(hull)-1]
+}
+
+// cross returns the XZ cross product of b-a and p-a,
+// positive when p is left of a to b
+func cross(a, b, p math32.Vector3) float32 {
+	return (b.X-a.X)*(p.Z-a.Z) - (b.Z-a.Z)*(p.X-a.X)
+}
+
+// polyArea returns the area of the counter clockwise poly
+func polyArea(poly []math32.Vector3) float32 {
+	var area float32
+	for i := range poly {
+		a, b := poly[i], poly[(i+1)%len(poly)]
+		area += a.X*b.Z - b.X*a.Z
+	}
+	return area / 2
+}
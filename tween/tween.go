@@ -0,0 +1,432 @@
+// Copyright 2017 Daniel Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tween plays node properties and values over time, with
+// easings, delays, sequences, parallel groups and cancellation.
+package tween
+
+import (
+	"reflect"
+
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+)
+
+// Tween is something a Player plays over time, see Player.Add
+type Tween interface {
+	// Update advances the tween by timeDelta seconds,
+	// it returns whether the tween is still playing
+	Update(timeDelta float64) bool
+	// Left returns the seconds of the last Update past the end of the
+	// tween, once it is complete
+	Left() float64
+	// Cancel stops the tween where it is, without calling its callback
+	Cancel()
+}
+
+// Easing maps the time along a tween, t from 0 to 1,
+// to how far along the value is
+type Easing func(t float32) float32
+
+// Linear moves at constant speed
+func Linear(t float32) float32 { return t }
+
+// EaseInQuad speeds up from the start
+func EaseInQuad(t float32) float32 { return t * t }
+
+// EaseOutQuad slows down towards the end
+func EaseOutQuad(t float32) float32 { return 1 - (1-t)*(1-t) }
+
+// EaseInOutQuad speeds up and then slows down
+func EaseInOutQuad(t float32) float32 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return 1 - 2*(1-t)*(1-t)
+}
+
+// EaseInCubic speeds up from the start, harder than EaseInQuad
+func EaseInCubic(t float32) float32 { return t * t * t }
+
+// EaseOutCubic slows down towards the end, harder than EaseOutQuad
+func EaseOutCubic(t float32) float32 { return 1 - (1-t)*(1-t)*(1-t) }
+
+// EaseInOutSine speeds up and slows down smoothly
+func EaseInOutSine(t float32) float32 { return (1 - math32.Cos(math32.Pi*t)) / 2 }
+
+// EaseOutBack overshoots the end a bit and comes back
+func EaseOutBack(t float32) float32 {
+	const s = 1.70158
+	u := t - 1
+	return 1 + (s+1)*u*u*u + s*u*u
+}
+
+// Animation tweens a property of a node, position, rotation, scale,
+// opacity or any value, over a duration with an easing
+type Animation struct {
+	node     *core.Node        // node to animate
+	dest     *math32.Vector3   // the destination of NewAnimation
+	speed    float32           // how many "blocks" per second
+	callback func(interface{}) // function to be called once animation is complete
+	cb_arg   interface{}       // arguments stored and passed in to the callback function
+
+	duration  float32                // seconds, from the end of the delay
+	delay     float32                // seconds before it starts
+	elapsed   float32                // seconds since it was added
+	ease      Easing                 // Linear when nil
+	begin     func() func(t float32) // reads the start values and returns the setter
+	set       func(t float32)        // sets the property t along, nil until started
+	cancelled bool                   // stopped by Cancel
+}
+
+// NewAnimation returns a pointer to a new Animation object
+// moving the node to dest at constant speed
+func NewAnimation(node *core.Node, dest *math32.Vector3, cb func(interface{}), cb_arg interface{}) *Animation {
+	a := new(Animation)
+	a.node = node
+	a.dest = dest
+	a.speed = 1
+	a.callback = cb
+	a.cb_arg = cb_arg
+	a.begin = func() func(t float32) {
+		from := node.Position()
+		a.duration = from.DistanceTo(dest) / a.speed
+		return lerpPosition(node, from, *dest)
+	}
+	return a
+}
+
+// MoveTo returns an animation moving node to pos
+func MoveTo(node *core.Node, pos math32.Vector3, duration float32, ease Easing) *Animation {
+	return newTween(node, duration, ease, func() func(t float32) {
+		return lerpPosition(node, node.Position(), pos)
+	})
+}
+
+// RotateTo returns an animation turning node to the euler angles rot,
+// along the shortest way
+func RotateTo(node *core.Node, rot math32.Vector3, duration float32, ease Easing) *Animation {
+	return newTween(node, duration, ease, func() func(t float32) {
+		from := node.Quaternion()
+		var to math32.Quaternion
+		to.SetFromEuler(&rot)
+		return func(t float32) {
+			q := from
+			q.Slerp(&to, t)
+			node.SetQuaternionQuat(&q)
+		}
+	})
+}
+
+// ScaleTo returns an animation scaling node to scale
+func ScaleTo(node *core.Node, scale math32.Vector3, duration float32, ease Easing) *Animation {
+	return newTween(node, duration, ease, func() func(t float32) {
+		from := node.Scale()
+		return func(t float32) {
+			s := from
+			s.Lerp(&scale, t)
+			node.SetScaleVec(&s)
+		}
+	})
+}
+
+// FadeTo returns an animation changing the opacity of the materials
+// under inode from alpha from to alpha to, their colours stay the same
+func FadeTo(inode core.INode, from, to, duration float32, ease Easing) *Animation {
+	return newTween(inode.GetNode(), duration, ease, func() func(t float32) {
+		mats := physicals(inode, nil)
+		colors := make([]math32.Color4, len(mats))
+		for i, mat := range mats {
+			colors[i] = baseColor(mat)
+		}
+		return func(t float32) {
+			for i, mat := range mats {
+				setAlpha(mat, colors[i], from+(to-from)*t)
+			}
+		}
+	})
+}
+
+// Value returns an animation calling set with the values from from to to
+func Value(from, to, duration float32, ease Easing, set func(v float32)) *Animation {
+	return newTween(nil, duration, ease, func() func(t float32) {
+		return func(t float32) {
+			set(from + (to-from)*t)
+		}
+	})
+}
+
+// newTween returns an animation of duration seconds, begin is called when
+// it starts, after its delay, to read the start values and return the setter
+func newTween(node *core.Node, duration float32, ease Easing, begin func() func(t float32)) *Animation {
+	a := new(Animation)
+	a.node = node
+	a.duration = duration
+	a.ease = ease
+	a.begin = begin
+	return a
+}
+
+// lerpPosition returns the setter moving node from from to to
+func lerpPosition(node *core.Node, from, to math32.Vector3) func(t float32) {
+	return func(t float32) {
+		p := from
+		p.Lerp(&to, t)
+		node.SetPositionVec(&p)
+	}
+}
+
+// SetAlpha makes every material under the node transparent with the
+// given alpha, keeping their colour
+func SetAlpha(inode core.INode, alpha float32) {
+	for _, mat := range physicals(inode, nil) {
+		setAlpha(mat, baseColor(mat), alpha)
+	}
+}
+
+// setAlpha makes mat transparent with color and the given alpha
+func setAlpha(mat *material.Physical, color math32.Color4, alpha float32) {
+	color.A = alpha
+	mat.SetTransparent(true)
+	mat.SetBaseColorFactor(&color)
+}
+
+// physicals appends the physical materials under the node to mats,
+// gltf models use physical materials
+func physicals(inode core.INode, mats []*material.Physical) []*material.Physical {
+	if gr, ok := inode.(graphic.IGraphic); ok {
+		for _, gm := range gr.GetGraphic().Materials() {
+			if mat, ok := gm.IMaterial().(*material.Physical); ok {
+				mats = append(mats, mat)
+			}
+		}
+	}
+	for _, child := range inode.GetNode().Children() {
+		mats = physicals(child, mats)
+	}
+	return mats
+}
+
+// baseColor returns the base color factor of mat,
+// the engine has a setter but no getter for it
+func baseColor(mat *material.Physical) math32.Color4 {
+	f := reflect.ValueOf(mat).Elem().FieldByName("udata").FieldByName("baseColorFactor")
+	return math32.Color4{
+		R: float32(f.FieldByName("R").Float()),
+		G: float32(f.FieldByName("G").Float()),
+		B: float32(f.FieldByName("B").Float()),
+		A: float32(f.FieldByName("A").Float()),
+	}
+}
+
+// Delay makes the animation wait that many seconds before starting
+func (a *Animation) Delay(seconds float32) *Animation {
+	a.delay = seconds
+	return a
+}
+
+// OnDone sets the function called with arg once the animation is complete
+func (a *Animation) OnDone(cb func(interface{}), arg interface{}) *Animation {
+	a.callback = cb
+	a.cb_arg = arg
+	return a
+}
+
+// Cancel stops the animation where it is, the callback is not called
+func (a *Animation) Cancel() {
+	a.cancelled = true
+}
+
+// Left returns the seconds of the last Update past the end of the animation
+func (a *Animation) Left() float64 {
+	if a.cancelled || a.set == nil {
+		return 0
+	}
+	return float64(math32.Max(a.elapsed-a.delay-a.duration, 0))
+}
+
+// Update moves the property towards its end according to the easing
+// and calls the callback with the previously provided args once finished
+func (a *Animation) Update(timeDelta float64) bool {
+	if a.cancelled {
+		return false
+	}
+	a.elapsed += float32(timeDelta)
+	if a.elapsed < a.delay {
+		return true
+	}
+	if a.set == nil {
+		a.set = a.begin()
+	}
+	t := float32(1)
+	if a.duration > 0 {
+		t = clamp((a.elapsed-a.delay)/a.duration, 0, 1)
+	}
+	ease := a.ease
+	if ease == nil {
+		ease = Linear
+	}
+	a.set(ease(t))
+	if t < 1 {
+		return true
+	}
+	if a.callback != nil {
+		a.callback(a.cb_arg)
+	}
+	return false
+}
+
+// clamp returns v between lo and hi
+func clamp(v, lo, hi float32) float32 {
+	return math32.Min(math32.Max(v, lo), hi)
+}
+
+// Sequence plays tweens one after the other
+type Sequence struct {
+	tweens []Tween
+	done   func()
+	left   float64
+}
+
+// NewSequence returns a *Sequence of the tweens
+func NewSequence(tweens ...Tween) *Sequence {
+	return &Sequence{tweens: tweens}
+}
+
+// OnDone sets the function called once the last tween is complete
+func (s *Sequence) OnDone(cb func()) *Sequence {
+	s.done = cb
+	return s
+}
+
+// Update plays the current tween and moves on when it is complete,
+// the next one plays the time the last one did not use
+func (s *Sequence) Update(timeDelta float64) bool {
+	for len(s.tweens) > 0 {
+		if s.tweens[0].Update(timeDelta) {
+			return true
+		}
+		timeDelta = s.tweens[0].Left()
+		s.tweens = s.tweens[1:]
+	}
+	s.left = timeDelta
+	if s.done != nil {
+		s.done()
+		s.done = nil
+	}
+	return false
+}
+
+// Left returns the seconds of the last Update past the end of the last tween
+func (s *Sequence) Left() float64 {
+	return s.left
+}
+
+// Cancel stops the current tween and skips the others
+func (s *Sequence) Cancel() {
+	if len(s.tweens) > 0 {
+		s.tweens[0].Cancel()
+	}
+	s.tweens = nil
+	s.done = nil
+	s.left = 0
+}
+
+// Parallel plays tweens together until they are all complete
+type Parallel struct {
+	tweens []Tween
+	done   func()
+	left   float64
+}
+
+// NewParallel returns a *Parallel of the tweens
+func NewParallel(tweens ...Tween) *Parallel {
+	return &Parallel{tweens: tweens}
+}
+
+// OnDone sets the function called once every tween is complete
+func (p *Parallel) OnDone(cb func()) *Parallel {
+	p.done = cb
+	return p
+}
+
+// Update plays all the tweens still playing
+func (p *Parallel) Update(timeDelta float64) bool {
+	playing := p.tweens[:0]
+	p.left = timeDelta
+	for _, t := range p.tweens {
+		if t.Update(timeDelta) {
+			playing = append(playing, t)
+		} else if left := t.Left(); left < p.left {
+			p.left = left
+		}
+	}
+	p.tweens = playing
+	if len(p.tweens) > 0 {
+		return true
+	}
+	if p.done != nil {
+		p.done()
+		p.done = nil
+	}
+	return false
+}
+
+// Left returns the seconds of the last Update past the end of the last
+// tween to complete
+func (p *Parallel) Left() float64 {
+	return p.left
+}
+
+// Cancel stops all the tweens
+func (p *Parallel) Cancel() {
+	for _, t := range p.tweens {
+		t.Cancel()
+	}
+	p.tweens = nil
+	p.done = nil
+	p.left = 0
+}
+
+// Player plays tweens until they are complete or cancelled
+type Player struct {
+	tweens  []Tween
+	current []Tween // tweens the Update in progress plays
+}
+
+// Add plays the tween from the next Update until it is complete or cancelled
+func (p *Player) Add(t Tween) Tween {
+	p.tweens = append(p.tweens, t)
+	return t
+}
+
+// CancelAll cancels all the ongoing tweens, the ones added later still play
+func (p *Player) CancelAll() {
+	for _, t := range p.current {
+		t.Cancel()
+	}
+	for _, t := range p.tweens {
+		t.Cancel()
+	}
+	p.tweens = nil
+}
+
+// Update advances all ongoing tweens by timeDelta seconds
+func (p *Player) Update(timeDelta float64) {
+	p.current = p.tweens
+	p.tweens = nil
+	for _, t := range p.current {
+		if t.Update(timeDelta) {
+			p.tweens = append(p.tweens, t)
+		}
+	}
+	p.current = nil
+}
+
+// Len returns how many tweens are playing
+func (p *Player) Len() int {
+	return len(p.tweens)
+}
@@ -0,0 +1,236 @@
+package tween
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+)
+
+// near returns whether a and b are within 1e-4
+func near(a, b float32) bool {
+	return math32.Abs(a-b) < 1e-4
+}
+
+// record returns a tween of v from from to to over duration seconds
+// that appends name to log once complete
+func record(v *float32, from, to, duration float32, name string, log *[]string) *Animation {
+	return Value(from, to, duration, Linear, func(x float32) { *v = x }).OnDone(func(interface{}) {
+		*log = append(*log, name)
+	}, nil)
+}
+
+func TestDelay(t *testing.T) {
+	v := float32(-1)
+	a := Value(0, 1, 1, Linear, func(x float32) { v = x }).Delay(0.5)
+	if !a.Update(0.4) || v != -1 {
+		t.Fatalf("during the delay the value is %v, want it untouched", v)
+	}
+	// starts where the delay ends
+	if !a.Update(0.2) || !near(v, 0.1) {
+		t.Fatalf("0.1s after the delay the value is %v, want 0.1", v)
+	}
+	if a.Update(0.95) || v != 1 {
+		t.Fatalf("after the end the value is %v, want 1", v)
+	}
+	if left := a.Left(); !near(float32(left), 0.05) {
+		t.Errorf("Left = %v, want 0.05", left)
+	}
+}
+
+func TestEasingEnds(t *testing.T) {
+	easings := map[string]Easing{
+		"Linear": Linear, "EaseInQuad": EaseInQuad, "EaseOutQuad": EaseOutQuad,
+		"EaseInOutQuad": EaseInOutQuad, "EaseInCubic": EaseInCubic, "EaseOutCubic": EaseOutCubic,
+		"EaseInOutSine": EaseInOutSine, "EaseOutBack": EaseOutBack,
+	}
+	for name, ease := range easings {
+		if got := ease(0); !near(got, 0) {
+			t.Errorf("%v(0) = %v, want 0", name, got)
+		}
+		if got := ease(1); !near(got, 1) {
+			t.Errorf("%v(1) = %v, want 1", name, got)
+		}
+		// a tween ends right on its end value, whatever the step
+		var v float32
+		a := Value(2, 5, 1, ease, func(x float32) { v = x })
+		for a.Update(0.3) {
+		}
+		if v != 5 {
+			t.Errorf("%v tween ended on %v, want 5", name, v)
+		}
+	}
+}
+
+func TestNodeTweens(t *testing.T) {
+	node := core.NewNode()
+	node.SetPosition(1, 0, 0)
+	p := Player{}
+	p.Add(MoveTo(node, math32.Vector3{X: 3, Z: 2}, 1, EaseInOutQuad))
+	p.Add(ScaleTo(node, math32.Vector3{X: 2, Y: 2, Z: 2}, 0.5, EaseOutBack))
+	p.Add(RotateTo(node, math32.Vector3{Y: math32.Pi / 3}, 1, Linear))
+
+	p.Update(0.5)
+	if pos := node.Position(); !near(pos.X, 2) || !near(pos.Z, 1) {
+		t.Errorf("halfway the node is at %v, want {2 0 1}", pos)
+	}
+	for i := 0; i < 3; i++ {
+		p.Update(0.25)
+	}
+	if p.Len() != 0 {
+		t.Fatalf("%v tweens still play after their end", p.Len())
+	}
+	if pos := node.Position(); pos != (math32.Vector3{X: 3, Z: 2}) {
+		t.Errorf("node ended at %v, want {3 0 2}", pos)
+	}
+	if s := node.Scale(); s != (math32.Vector3{X: 2, Y: 2, Z: 2}) {
+		t.Errorf("node ended scaled %v, want {2 2 2}", s)
+	}
+	rot := node.Rotation()
+	if !near(rot.X, 0) || !near(rot.Y, math32.Pi/3) || !near(rot.Z, 0) {
+		t.Errorf("node ended turned %v, want {0 pi/3 0}", rot)
+	}
+}
+
+func TestFadeKeepsColor(t *testing.T) {
+	tint := math32.Color4{R: 0.86, G: 0.72, B: 0.4, A: 1}
+	mat := material.NewPhysical().SetBaseColorFactor(&tint)
+	model := core.NewNode()
+	model.Add(graphic.NewMesh(geometry.NewGeometry(), mat))
+
+	a := FadeTo(model, 1, 0, 1, Linear)
+	a.Update(0.25)
+	if c := baseColor(mat); c.R != tint.R || c.G != tint.G || c.B != tint.B || !near(c.A, 0.75) {
+		t.Errorf("a quarter through the fade the colour is %v, want %v with alpha 0.75", c, tint)
+	}
+	a.Update(1)
+	if c := baseColor(mat); c != (math32.Color4{R: tint.R, G: tint.G, B: tint.B}) {
+		t.Errorf("after the fade the colour is %v, want %v with alpha 0", c, tint)
+	}
+	if !mat.Transparent() {
+		t.Error("faded material is not transparent")
+	}
+}
+
+func TestSequenceOrder(t *testing.T) {
+	var log []string
+	var a, b float32
+	s := NewSequence(record(&a, 0, 1, 1, "a", &log), record(&b, 0, 1, 1, "b", &log))
+	s.OnDone(func() { log = append(log, "done") })
+
+	if !s.Update(0.5) || !near(a, 0.5) || b != 0 {
+		t.Fatalf("first update gave a %v b %v, want a 0.5 and b untouched", a, b)
+	}
+	// the time left by a plays b
+	if !s.Update(0.75) || a != 1 || !near(b, 0.25) {
+		t.Fatalf("second update gave a %v b %v, want a 1 and b 0.25", a, b)
+	}
+	if s.Update(1) || b != 1 {
+		t.Fatalf("third update gave b %v and still plays, want it done", b)
+	}
+	if want := []string{"a", "b", "done"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("callbacks ran %v, want %v", log, want)
+	}
+	if left := s.Left(); !near(float32(left), 0.25) {
+		t.Errorf("Left = %v, want 0.25", left)
+	}
+}
+
+func TestSequenceCarriesTimeAcrossTweens(t *testing.T) {
+	var a, b, c float32
+	var log []string
+	s := NewSequence(record(&a, 0, 1, 0.1, "a", &log), record(&b, 0, 1, 0.1, "b", &log), record(&c, 0, 1, 1, "c", &log))
+	// one long step plays a and b through and c a bit
+	if !s.Update(0.3) || a != 1 || b != 1 || !near(c, 0.1) {
+		t.Errorf("after one step a %v b %v c %v, want 1 1 0.1", a, b, c)
+	}
+}
+
+func TestParallelCompletion(t *testing.T) {
+	var log []string
+	var a, b float32
+	p := NewParallel(record(&a, 0, 1, 1, "a", &log), record(&b, 0, 1, 2, "b", &log))
+	p.OnDone(func() { log = append(log, "done") })
+
+	if !p.Update(0.5) || !near(a, 0.5) || !near(b, 0.25) {
+		t.Fatalf("first update gave a %v b %v, want 0.5 and 0.25", a, b)
+	}
+	if !p.Update(1) || a != 1 || !near(b, 0.75) {
+		t.Fatalf("second update gave a %v b %v, want 1 and 0.75 still playing", a, b)
+	}
+	if want := []string{"a"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("callbacks ran %v, want %v", log, want)
+	}
+	if p.Update(0.6) || b != 1 {
+		t.Fatalf("third update gave b %v and still plays, want it done", b)
+	}
+	if want := []string{"a", "b", "done"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("callbacks ran %v, want %v", log, want)
+	}
+	if left := p.Left(); !near(float32(left), 0.1) {
+		t.Errorf("Left = %v, want 0.1", left)
+	}
+}
+
+func TestCancelSkipsCallbacks(t *testing.T) {
+	var log []string
+	var v float32
+
+	a := record(&v, 0, 1, 1, "animation", &log)
+	a.Update(0.5)
+	a.Cancel()
+	if a.Update(1) || v != 0.5 {
+		t.Errorf("cancelled animation moved to %v or still plays", v)
+	}
+
+	s := NewSequence(record(&v, 0, 1, 1, "sequence tween", &log), record(&v, 0, 1, 1, "sequence tween", &log))
+	s.OnDone(func() { log = append(log, "sequence") })
+	s.Update(0.5)
+	s.Cancel()
+	if s.Update(2) {
+		t.Error("cancelled sequence still plays")
+	}
+
+	p := NewParallel(record(&v, 0, 1, 1, "parallel tween", &log))
+	p.OnDone(func() { log = append(log, "parallel") })
+	p.Update(0.5)
+	p.Cancel()
+	if p.Update(2) {
+		t.Error("cancelled parallel still plays")
+	}
+
+	if len(log) > 0 {
+		t.Errorf("cancelled tweens called back %v", log)
+	}
+}
+
+func TestPlayerCancelAll(t *testing.T) {
+	var log []string
+	var a, b, c float32
+	p := Player{}
+	p.Add(record(&a, 0, 1, 1, "a", &log))
+	p.Add(NewSequence(record(&b, 0, 1, 1, "b", &log)))
+	p.Update(0.5)
+	p.CancelAll()
+	// a tween added after CancelAll plays
+	p.Add(record(&c, 0, 1, 1, "c", &log))
+	p.Update(1)
+	if !near(a, 0.5) || !near(b, 0.5) || c != 1 {
+		t.Errorf("after CancelAll a %v b %v c %v, want 0.5 0.5 1", a, b, c)
+	}
+	if want := []string{"c"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("callbacks ran %v, want %v", log, want)
+	}
+
+	// cancelling from a callback stops the tweens of the same Update
+	p.Add(Value(0, 1, 1, Linear, func(float32) {}).OnDone(func(interface{}) { p.CancelAll() }, nil))
+	p.Add(record(&a, 0, 1, 1, "late", &log))
+	p.Update(1)
+	if p.Len() != 0 || len(log) != 1 {
+		t.Errorf("CancelAll from a callback left %v tweens and ran %v", p.Len(), log)
+	}
+}
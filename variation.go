@@ -0,0 +1,40 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/g3n/engine/math32"
+)
+
+// TintCloth multiplies the skin colors by tint where the mask is opaque
+func TintCloth(skin, mask image.Image, tint math32.Color) image.Image {
+	sb := skin.Bounds()
+	mb := mask.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, sb.Dx(), sb.Dy()))
+	for y := 0; y < sb.Dy(); y++ {
+		for x := 0; x < sb.Dx(); x++ {
+			r, g, b, a := skin.At(sb.Min.X+x, sb.Min.Y+y).RGBA()
+			// the mask may be smaller than the skin, stretch it
+			mx := mb.Min.X + x*mb.Dx()/sb.Dx()
+			my := mb.Min.Y + y*mb.Dy()/sb.Dy()
+			_, _, _, ma := mask.At(mx, my).RGBA()
+			w := float32(ma) / 0xffff
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(float32(r) * (1 - w + w*tint.R)),
+				G: uint16(float32(g) * (1 - w + w*tint.G)),
+				B: uint16(float32(b) * (1 - w + w*tint.B)),
+				A: uint16(a),
+			})
+		}
+	}
+	return dst
+}
+
+// applyTraits scales the character model and its name tag
+func (tf *TheFarm) applyTraits(char *TheChar, arch Archetype) {
+	scaleModel(char, 1)
+	if char.Tag != nil {
+		char.Tag.sprite.SetPositionY(arch.TagHeight * char.Traits.Height)
+	}
+}